@@ -0,0 +1,100 @@
+package gccjit
+
+import (
+	"math/big"
+	"reflect"
+)
+
+// Number is the set of Go types accepted by Const.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~bool
+}
+
+// NewRvalueFromInt128 builds a 128-bit integer constant of typ from its high
+// and low 64-bit halves. libgccjit has no 128-bit literal entry point, so the
+// value is assembled as (hi << 64) | lo and folded by GCC.
+func (c *Context) NewRvalueFromInt128(loc *Location, typ *Type, hi, lo uint64) *Rvalue {
+	u128 := c.GetType(TYPE_UINT128_T)
+	u64 := c.GetType(TYPE_UINT64_T)
+
+	high := c.NewCast(loc, c.NewRValueFromLong(u64, int64(hi)), u128)
+	low := c.NewCast(loc, c.NewRValueFromLong(u64, int64(lo)), u128)
+	shift := c.NewRValueFromInt(u128, 64)
+
+	value := c.NewBinaryOp(
+		loc,
+		BINARY_OP_BITWISE_OR,
+		u128,
+		c.NewBinaryOp(loc, BINARY_OP_LSHIFT, u128, high, shift),
+		low,
+	)
+
+	return c.NewCast(loc, value, typ)
+}
+
+// NewRvalueFromBigInt builds a 128-bit integer constant of typ. Values outside
+// the 128-bit range are truncated to their low 128 bits in two's complement,
+// as a C conversion would.
+func (c *Context) NewRvalueFromBigInt(loc *Location, typ *Type, value *big.Int) *Rvalue {
+	hi, lo := splitInt128(value)
+	return c.NewRvalueFromInt128(loc, typ, hi, lo)
+}
+
+// splitInt128 returns the high and low 64 bits of value in 128-bit two's
+// complement. big.Int's bitwise operations treat negative values as two's
+// complement of unbounded width, so masking gives the truncation directly.
+func splitInt128(value *big.Int) (hi, lo uint64) {
+	mask := new(big.Int).SetUint64(^uint64(0))
+	lo = new(big.Int).And(value, mask).Uint64()
+	hi = new(big.Int).And(new(big.Int).Rsh(value, 64), mask).Uint64()
+
+	return hi, lo
+}
+
+// GoType returns the gccjit type with the size and representation of the Go
+// type t on the current platform, or nil if t has no C counterpart. Integers
+// are looked up by width, so Go's int always matches, whatever the C data
+// model makes of long.
+func (c *Context) GoType(t reflect.Type) *Type {
+	switch t.Kind() {
+	case reflect.Bool:
+		return c.GetType(TYPE_BOOL)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return c.GetIntType(int(t.Size()), true)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return c.GetIntType(int(t.Size()), false)
+	case reflect.Float32:
+		return c.GetType(TYPE_FLOAT)
+	case reflect.Float64:
+		return c.GetType(TYPE_DOUBLE)
+	case reflect.UnsafePointer:
+		return c.GetType(TYPE_VOID_PTR)
+	default:
+		return nil
+	}
+}
+
+// Const builds a constant whose gccjit type is picked from the Go type of
+// value with GoType, e.g. int32 becomes a 4-byte signed integer and float64
+// becomes TYPE_DOUBLE.
+func Const[T Number](c *Context, value T) *Rvalue {
+	v := reflect.ValueOf(value)
+	typ := c.GoType(v.Type())
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return c.One(typ)
+		}
+
+		return c.Zero(typ)
+	case reflect.Float32, reflect.Float64:
+		return c.NewRvalueFromDouble(typ, v.Float())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return c.NewRValueFromLong(typ, v.Int())
+	default:
+		return c.NewRValueFromLong(typ, int64(v.Uint()))
+	}
+}
@@ -0,0 +1,41 @@
+package gccjit
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSplitInt128(t *testing.T) {
+	pow := func(n uint) *big.Int {
+		return new(big.Int).Lsh(big.NewInt(1), n)
+	}
+
+	tests := []struct {
+		name   string
+		value  *big.Int
+		hi, lo uint64
+	}{
+		{"zero", big.NewInt(0), 0, 0},
+		{"one", big.NewInt(1), 0, 1},
+		{"minus one", big.NewInt(-1), ^uint64(0), ^uint64(0)},
+		{"low word max", new(big.Int).SetUint64(^uint64(0)), 0, ^uint64(0)},
+		{"2^64", pow(64), 1, 0},
+		{"-2^64", new(big.Int).Neg(pow(64)), ^uint64(0), 0},
+		{"int128 max", new(big.Int).Sub(pow(127), big.NewInt(1)), 1<<63 - 1, ^uint64(0)},
+		{"int128 min", new(big.Int).Neg(pow(127)), 1 << 63, 0},
+		{"uint128 max", new(big.Int).Sub(pow(128), big.NewInt(1)), ^uint64(0), ^uint64(0)},
+		{"2^128 truncates", pow(128), 0, 0},
+		{"2^128+5 truncates", new(big.Int).Add(pow(128), big.NewInt(5)), 0, 5},
+		{"-2^200 truncates", new(big.Int).Neg(pow(200)), 0, 0},
+		{"-2^128-1 truncates", new(big.Int).Sub(new(big.Int).Neg(pow(128)), big.NewInt(1)), ^uint64(0), ^uint64(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hi, lo := splitInt128(tt.value)
+			if hi != tt.hi || lo != tt.lo {
+				t.Errorf("splitInt128(%v) = %#x, %#x, want %#x, %#x", tt.value, hi, lo, tt.hi, tt.lo)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package gccjit
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// DumpHandle receives the text of one GCC internal dump enabled with
+// Context.EnableDump. The text is filled in by each Compile of the context.
+type DumpHandle struct {
+	mu   sync.Mutex
+	slot *unsafe.Pointer // C memory libgccjit writes the dump buffer into
+	name unsafe.Pointer  // C copy of the dump name, read by libgccjit at compile time
+	text string
+}
+
+// Dump handles are owned by their context, because libgccjit keeps writing
+// into their slot on every compile until the context is released.
+var (
+	dumpsMu sync.Mutex
+	dumps   = map[*Context][]*DumpHandle{}
+)
+
+// EnableDump asks GCC to capture the dump called name, such as "tree-vrp1"
+// or "rtl-final", when c is compiled. The names are those accepted by GCC's
+// -fdump- options.
+func (c *Context) EnableDump(name string) *DumpHandle {
+	c.mustBeLive()
+
+	slot := (*unsafe.Pointer)(libcMalloc(unsafe.Sizeof(unsafe.Pointer(nil))))
+	*slot = nil
+
+	// libgccjit keeps the name pointer rather than copying the string, so
+	// it has to live in C memory until c is released.
+	d := &DumpHandle{slot: slot, name: cStringMalloc(name)}
+	contextEnableDump(c, d.name, unsafe.Pointer(slot))
+
+	dumpsMu.Lock()
+	dumps[c] = append(dumps[c], d)
+	dumpsMu.Unlock()
+
+	return d
+}
+
+// String returns the text of the dump from the latest Compile, or "" if the
+// context has not been compiled yet.
+func (d *DumpHandle) String() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.collect()
+
+	return d.text
+}
+
+// collect takes ownership of a buffer written by libgccjit since the last
+// call. d.mu must be held.
+func (d *DumpHandle) collect() {
+	if d.slot == nil || *d.slot == nil {
+		return
+	}
+
+	d.text = goString(*d.slot)
+	libcFree(*d.slot)
+	*d.slot = nil
+}
+
+// collectDumps takes the buffers written by a compile of c. libgccjit
+// overwrites the slot on the next compile without freeing what it held, so
+// this must run after every compile. Dumps enabled on the ancestors of c are
+// written by its compiles too.
+func collectDumps(c *Context) {
+	for ; c != nil; c = parentOf(c) {
+		dumpsMu.Lock()
+		handles := dumps[c]
+		dumpsMu.Unlock()
+
+		for _, d := range handles {
+			d.mu.Lock()
+			d.collect()
+			d.mu.Unlock()
+		}
+	}
+}
+
+// releaseDumps keeps the text of c's dump handles and frees their C memory.
+// It must run after c itself is released.
+func releaseDumps(c *Context) {
+	dumpsMu.Lock()
+	handles := dumps[c]
+	delete(dumps, c)
+	dumpsMu.Unlock()
+
+	for _, d := range handles {
+		d.mu.Lock()
+		d.collect()
+		libcFree(unsafe.Pointer(d.slot))
+		libcFree(d.name)
+		d.slot = nil
+		d.name = nil
+		d.mu.Unlock()
+	}
+}
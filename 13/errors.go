@@ -0,0 +1,108 @@
+package gccjit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// CompileError is returned when libgccjit reports an error on a context.
+// libgccjit keeps the first and the most recent error; the first is usually
+// the cause and later ones follow from it.
+type CompileError struct {
+	First string
+	Last  string
+
+	// File, Line and Column locate the first error when its message starts
+	// with a "file:line:column:" prefix. Message is First without it.
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+var errorLocation = regexp.MustCompile(`^(.+?):(\d+):(\d+): (?:error: )?(.*)$`)
+
+func newCompileError(first, last string) *CompileError {
+	e := &CompileError{First: first, Last: last, Message: first}
+
+	if m := errorLocation.FindStringSubmatch(first); m != nil {
+		e.File = m[1]
+		e.Line, _ = strconv.Atoi(m[2])
+		e.Column, _ = strconv.Atoi(m[3])
+		e.Message = m[4]
+	}
+
+	return e
+}
+
+func (e *CompileError) Error() string {
+	if e.First == "" {
+		return "gccjit: compilation failed"
+	}
+
+	return "gccjit: " + e.First
+}
+
+// Err returns the errors reported on c so far as a *CompileError, or nil if
+// there are none.
+func (c *Context) Err() error {
+	first := c.GetFirstError()
+	if first == "" {
+		return nil
+	}
+
+	return newCompileError(first, c.GetLastError())
+}
+
+// CompileE is Compile returning a *CompileError instead of a nil *Result.
+func (c *Context) CompileE() (*Result, error) {
+	r := c.Compile()
+	if r == nil {
+		return nil, newCompileError(c.GetFirstError(), c.GetLastError())
+	}
+
+	return r, nil
+}
+
+// CompileToFileE is CompileToFile reporting failure as a *CompileError.
+func (c *Context) CompileToFileE(outputKind OutputKind, outputPath string) error {
+	c.CompileToFile(outputKind, outputPath)
+
+	return c.Err()
+}
+
+// Lookup returns the address of the compiled function name, or an error if
+// r has no such function.
+func (r *Result) Lookup(name string) (uintptr, error) {
+	ptr := r.GetCode(name)
+	if ptr == 0 {
+		return 0, fmt.Errorf("gccjit: function %q not found in result", name)
+	}
+
+	return ptr, nil
+}
+
+// LookupGlobal returns the address of the compiled global name, or an error
+// if r has no such global.
+func (r *Result) LookupGlobal(name string) (uintptr, error) {
+	ptr := r.GetGlobal(name)
+	if ptr == 0 {
+		return 0, fmt.Errorf("gccjit: global %q not found in result", name)
+	}
+
+	return ptr, nil
+}
+
+// RegisterFuncE is RegisterFunc returning an error instead of binding fn to
+// a nil address when r has no function called name.
+func (r *Result) RegisterFuncE(name string, fn any) error {
+	ptr, err := r.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	bindFunc(r, fn, ptr)
+
+	return nil
+}
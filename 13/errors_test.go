@@ -0,0 +1,78 @@
+package gccjit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewCompileError(t *testing.T) {
+	tests := []struct {
+		name        string
+		first, last string
+		want        CompileError
+		text        string
+	}{
+		{
+			name:  "located",
+			first: "prog.c:12:5: error: unknown field 'x'",
+			last:  "prog.c:14:1: error: unreachable block",
+			want: CompileError{
+				First:   "prog.c:12:5: error: unknown field 'x'",
+				Last:    "prog.c:14:1: error: unreachable block",
+				File:    "prog.c",
+				Line:    12,
+				Column:  5,
+				Message: "unknown field 'x'",
+			},
+			text: "gccjit: prog.c:12:5: error: unknown field 'x'",
+		},
+		{
+			name:  "located without error label",
+			first: "/tmp/gen/main.bf:3:17: unbalanced ']'",
+			want: CompileError{
+				First:   "/tmp/gen/main.bf:3:17: unbalanced ']'",
+				File:    "/tmp/gen/main.bf",
+				Line:    3,
+				Column:  17,
+				Message: "unbalanced ']'",
+			},
+			text: "gccjit: /tmp/gen/main.bf:3:17: unbalanced ']'",
+		},
+		{
+			name:  "api misuse",
+			first: "gcc_jit_block_add_assignment: mismatching types: assignment to i (type: int) from 1.0 (type: double)",
+			want: CompileError{
+				First:   "gcc_jit_block_add_assignment: mismatching types: assignment to i (type: int) from 1.0 (type: double)",
+				Message: "gcc_jit_block_add_assignment: mismatching types: assignment to i (type: int) from 1.0 (type: double)",
+			},
+			text: "gccjit: gcc_jit_block_add_assignment: mismatching types: assignment to i (type: int) from 1.0 (type: double)",
+		},
+		{
+			name:  "colons but no column",
+			first: "main.c:7: error: oops",
+			want: CompileError{
+				First:   "main.c:7: error: oops",
+				Message: "main.c:7: error: oops",
+			},
+			text: "gccjit: main.c:7: error: oops",
+		},
+		{
+			name: "empty",
+			want: CompileError{},
+			text: "gccjit: compilation failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newCompileError(tt.first, tt.last)
+			if !reflect.DeepEqual(*e, tt.want) {
+				t.Errorf("newCompileError(%q, %q) = %+v, want %+v", tt.first, tt.last, *e, tt.want)
+			}
+
+			if got := e.Error(); got != tt.text {
+				t.Errorf("Error() = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package gccjit
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+// Func looks up the compiled code for fn in r and binds it to a Go function
+// of type F. It checks F against the signature fn was declared with, so a
+// missing symbol or a parameter of the wrong size is reported as an error
+// rather than corrupting arguments at call time. The context fn belongs to
+// must not have been released yet, since its signature is read from it.
+//
+// The returned func keeps r loaded while it is reachable.
+func Func[F any](r *Result, fn *Function) (F, error) {
+	var f F
+
+	goType := reflect.TypeOf(&f).Elem()
+	if goType.Kind() != reflect.Func {
+		return f, fmt.Errorf("gccjit: Func needs a function type, got %s", goType)
+	}
+
+	name := fn.GetDebugString()
+	if err := checkSignature(goType, fn); err != nil {
+		return f, fmt.Errorf("gccjit: %s: %w", name, err)
+	}
+
+	ptr, err := r.Lookup(name)
+	if err != nil {
+		return f, err
+	}
+
+	bindFunc(r, &f, ptr)
+
+	return f, nil
+}
+
+func checkSignature(goType reflect.Type, fn *Function) error {
+	if goType.IsVariadic() {
+		return fmt.Errorf("variadic Go function %s is not supported", goType)
+	}
+
+	if n := fn.GetParamCount(); uint64(goType.NumIn()) != n {
+		return fmt.Errorf("%s has %d parameters, the JIT function has %d", goType, goType.NumIn(), n)
+	}
+
+	for i := 0; i < goType.NumIn(); i++ {
+		if err := checkCallable(goType.In(i), false); err != nil {
+			return fmt.Errorf("parameter %d: %w", i, err)
+		}
+
+		jitType := fn.GetParam(i).AsRvalue().GetType()
+		if err := checkType(goType.In(i), jitType); err != nil {
+			return fmt.Errorf("parameter %d: %w", i, err)
+		}
+	}
+
+	returnType := fn.GetReturnType()
+	isVoid := returnType.Unqualified() == fn.GetContext().GetType(TYPE_VOID)
+
+	switch goType.NumOut() {
+	case 0:
+		if !isVoid {
+			return fmt.Errorf("%s returns nothing, the JIT function returns %s", goType, returnType.GetDebugString())
+		}
+	case 1:
+		if isVoid {
+			return fmt.Errorf("%s returns %s, the JIT function returns void", goType, goType.Out(0))
+		}
+
+		if err := checkCallable(goType.Out(0), true); err != nil {
+			return fmt.Errorf("result: %w", err)
+		}
+
+		if err := checkType(goType.Out(0), returnType); err != nil {
+			return fmt.Errorf("result: %w", err)
+		}
+	default:
+		return fmt.Errorf("%s returns more than one value", goType)
+	}
+
+	return nil
+}
+
+// checkCallable reports whether purego can pass values of Go type t to C,
+// or return them from C if result is set, on this platform.
+func checkCallable(t reflect.Type, result bool) error {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if !puregoFloats {
+			return fmt.Errorf("Go type %s cannot be passed to or returned from C when built with cgo on %s", t, runtime.GOOS)
+		}
+	case reflect.Func:
+		// Passing a func needs a C callback, which purego cannot make on
+		// Linux. A returned function pointer is bound like any other.
+		if !result && runtime.GOOS == "linux" {
+			return fmt.Errorf("Go type %s cannot be passed to C on %s", t, runtime.GOOS)
+		}
+	}
+
+	return nil
+}
+
+// checkType reports whether values of Go type t are passed the way C passes
+// values of jitType. Sizes are only asked of integer and floating-point
+// types, the only ones gcc_jit_type_get_size accepts besides pointers.
+func checkType(t reflect.Type, jitType *Type) error {
+	jitType = jitType.Unqualified()
+	mismatch := fmt.Errorf("Go type %s does not match %s", t, jitType.GetDebugString())
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if !jitType.IsBool() {
+			return mismatch
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !jitType.IsIntegral() || jitType.IsBool() || jitType.GetSize() != uint64(t.Size()) {
+			return mismatch
+		}
+	case reflect.Float32, reflect.Float64:
+		if !isFloatType(jitType) || jitType.GetSize() != uint64(t.Size()) {
+			return mismatch
+		}
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Slice, reflect.String, reflect.Func:
+		if !jitType.IsPointer() {
+			return mismatch
+		}
+	default:
+		return fmt.Errorf("Go type %s cannot be passed to or returned from C", t)
+	}
+
+	return nil
+}
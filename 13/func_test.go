@@ -0,0 +1,98 @@
+package gccjit
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCheckSignature(t *testing.T) {
+	if !Available() {
+		t.Skip("libgccjit is not available")
+	}
+
+	c := ContextAcquire()
+	defer c.Release()
+
+	intType := c.GetType(TYPE_INT)
+	doubleType := c.GetType(TYPE_DOUBLE)
+	voidType := c.GetType(TYPE_VOID)
+	callbackType := c.NewFunctionPtrType(nil, voidType, nil, false)
+
+	newFunction := func(name string, ret *Type, params ...*Type) *Function {
+		var ps []*Param
+		for i, typ := range params {
+			ps = append(ps, c.NewParam(nil, typ, string(rune('a'+i))))
+		}
+
+		return c.NewFunction(nil, FUNCTION_EXPORTED, ret, name, ps, false)
+	}
+
+	floats := ""
+	if !puregoFloats {
+		floats = "when built with cgo"
+	}
+
+	callbacks := ""
+	if runtime.GOOS == "linux" {
+		callbacks = "cannot be passed to C"
+	}
+
+	tests := []struct {
+		name   string
+		goFunc any
+		fn     *Function
+		want   string
+	}{
+		{"ints", func(int32, int64) int32 { return 0 }, newFunction("ints", intType, intType, c.GetType(TYPE_LONG_LONG)), ""},
+		{"void", func() {}, newFunction("void", voidType), ""},
+		{"parameter count", func(int32) {}, newFunction("count", voidType), "has 1 parameters"},
+		{"int size", func(int64) {}, newFunction("size", voidType, intType), "does not match"},
+		{"missing result", func() {}, newFunction("missing", intType), "returns nothing"},
+		{"float parameter", func(float64) {}, newFunction("fparam", voidType, doubleType), floats},
+		{"float result", func() float64 { return 0 }, newFunction("fresult", doubleType), floats},
+		{"func parameter", func(func()) {}, newFunction("cbparam", voidType, callbackType), callbacks},
+		{"func result", func() func() { return nil }, newFunction("cbresult", callbackType), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSignature(reflect.TypeOf(tt.goFunc), tt.fn)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("checkSignature: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("checkSignature error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCallable(t *testing.T) {
+	linux := runtime.GOOS == "linux"
+
+	tests := []struct {
+		name    string
+		goType  any
+		result  bool
+		wantErr bool
+	}{
+		{"int parameter", int32(0), false, false},
+		{"pointer result", (*byte)(nil), true, false},
+		{"float32 parameter", float32(0), false, !puregoFloats},
+		{"float64 parameter", float64(0), false, !puregoFloats},
+		{"float64 result", float64(0), true, !puregoFloats},
+		{"func parameter", func() {}, false, linux},
+		{"func result", func() {}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCallable(reflect.TypeOf(tt.goType), tt.result)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkCallable error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
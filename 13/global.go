@@ -0,0 +1,219 @@
+package gccjit
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// The types of globals are recorded when they are declared, and their sizes
+// are resolved when the context is compiled, because a Result outlives the
+// context and the types it made.
+var (
+	globalsMu     sync.Mutex
+	globalTypes   = map[*Context]map[string]*Type{}
+	resultGlobals = map[*Result]map[string]globalSize{}
+)
+
+type globalSize struct {
+	size  uint64
+	known bool
+}
+
+func recordGlobal(c *Context, name string, typ *Type) {
+	globalsMu.Lock()
+	defer globalsMu.Unlock()
+
+	if globalTypes[c] == nil {
+		globalTypes[c] = map[string]*Type{}
+	}
+
+	globalTypes[c][name] = typ
+}
+
+// recordResult resolves the sizes of the globals visible to c, including
+// those declared in its ancestors, for later lookups on r.
+func recordResult(c *Context, r *Result) {
+	globalsMu.Lock()
+	defer globalsMu.Unlock()
+
+	sizes := map[string]globalSize{}
+	for ctx := c; ctx != nil; ctx = parentOf(ctx) {
+		for name, typ := range globalTypes[ctx] {
+			if _, ok := sizes[name]; ok {
+				continue
+			}
+
+			size, known := typeSize(typ)
+			sizes[name] = globalSize{size: size, known: known}
+		}
+	}
+
+	resultGlobals[r] = sizes
+}
+
+func forgetContextGlobals(c *Context) {
+	globalsMu.Lock()
+	delete(globalTypes, c)
+	globalsMu.Unlock()
+}
+
+func forgetResultGlobals(r *Result) {
+	globalsMu.Lock()
+	delete(resultGlobals, r)
+	globalsMu.Unlock()
+}
+
+// isFloatType reports whether t is float, double or long double.
+func isFloatType(t *Type) bool {
+	t = t.Unqualified()
+	c := t.GetContext()
+
+	return t == c.GetType(TYPE_FLOAT) || t == c.GetType(TYPE_DOUBLE) || t == c.GetType(TYPE_LONG_DOUBLE)
+}
+
+// typeSize returns the size of t in bytes. libgccjit only reports the size
+// of scalar types and exposes no struct layout, so the size of arrays and
+// vectors is derived from their elements and that of structs and unions is
+// unknown.
+func typeSize(t *Type) (uint64, bool) {
+	t = t.Unqualified()
+
+	switch {
+	case t.IsBool():
+		return 1, true
+	case t.IsIntegral(), t.IsPointer(), isFloatType(t):
+		return t.GetSize(), true
+	}
+
+	if elem := t.DyncastArray(); elem != nil {
+		n, ok := t.GetArrayLength()
+		size, elemOK := typeSize(elem)
+
+		return uint64(n) * size, ok && elemOK
+	}
+
+	if vec := t.DyncastVector(); vec != nil {
+		size, ok := typeSize(vec.GetElementType())
+
+		return vec.GetNumUnits() * size, ok
+	}
+
+	return 0, false
+}
+
+// cPointer converts the address of C memory to an unsafe.Pointer. Going
+// through memory keeps vet from flagging a uintptr that is not a Go pointer.
+func cPointer(addr uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+}
+
+func checkGlobal(r *Result, name string, size uint64) (uintptr, error) {
+	ptr, err := r.LookupGlobal(name)
+	if err != nil {
+		return 0, err
+	}
+
+	globalsMu.Lock()
+	g, ok := resultGlobals[r][name]
+	globalsMu.Unlock()
+
+	if ok && g.known && g.size != size {
+		return 0, fmt.Errorf("gccjit: global %q is %d bytes, Go type needs %d", name, g.size, size)
+	}
+
+	return ptr, nil
+}
+
+// GlobalRef is a compiled global of type T. It keeps the Result it came from
+// loaded for as long as it is reachable, so it stays usable after the Result
+// is released.
+type GlobalRef[T any] struct {
+	ptr  *T
+	hold *codeHold
+}
+
+// Global returns the compiled global name, typed as T. The size of T must
+// match the type the global was declared with in NewGlobal; for struct and
+// union globals, whose size libgccjit does not report, only the existence of
+// the global is checked.
+func Global[T any](r *Result, name string) (*GlobalRef[T], error) {
+	var zero T
+
+	ptr, err := checkGlobal(r, name, uint64(unsafe.Sizeof(zero)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GlobalRef[T]{ptr: (*T)(cPointer(ptr)), hold: holdResult(r)}, nil
+}
+
+// Ptr returns a pointer to the global. The pointer does not keep the code
+// loaded by itself, so g must be kept reachable while it is in use, for
+// example with runtime.KeepAlive.
+func (g *GlobalRef[T]) Ptr() *T {
+	return g.ptr
+}
+
+// Load returns the value of the global.
+func (g *GlobalRef[T]) Load() T {
+	defer runtime.KeepAlive(g)
+	return *g.ptr
+}
+
+// Store sets the global to v.
+func (g *GlobalRef[T]) Store(v T) {
+	defer runtime.KeepAlive(g)
+	*g.ptr = v
+}
+
+// GlobalSliceRef is a compiled global holding n elements of type T. Like
+// GlobalRef, it keeps the Result it came from loaded while it is reachable.
+type GlobalSliceRef[T any] struct {
+	elems []T
+	hold  *codeHold
+}
+
+// GlobalSlice returns the compiled global name as n elements of type T, such
+// as an array global declared with GetArrayType. The global must be exactly
+// n elements of T in size, with the same exception for structs and unions as
+// Global.
+func GlobalSlice[T any](r *Result, name string, n int) (*GlobalSliceRef[T], error) {
+	if n < 0 {
+		return nil, fmt.Errorf("gccjit: negative length %d for global %q", n, name)
+	}
+
+	elem := reflect.TypeOf((*T)(nil)).Elem()
+
+	ptr, err := checkGlobal(r, name, uint64(n)*uint64(elem.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GlobalSliceRef[T]{elems: unsafe.Slice((*T)(cPointer(ptr)), n), hold: holdResult(r)}, nil
+}
+
+// Slice returns the elements of the global. As with GlobalRef.Ptr, g must be
+// kept reachable while the slice is in use.
+func (g *GlobalSliceRef[T]) Slice() []T {
+	return g.elems
+}
+
+// Len returns the number of elements in the global.
+func (g *GlobalSliceRef[T]) Len() int {
+	return len(g.elems)
+}
+
+// Get returns element i of the global.
+func (g *GlobalSliceRef[T]) Get(i int) T {
+	defer runtime.KeepAlive(g)
+	return g.elems[i]
+}
+
+// Set sets element i of the global to v.
+func (g *GlobalSliceRef[T]) Set(i int, v T) {
+	defer runtime.KeepAlive(g)
+	g.elems[i] = v
+}
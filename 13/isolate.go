@@ -0,0 +1,335 @@
+package gccjit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Isolated compilation runs a build in a child process started from the
+// same binary, so an internal compiler error or abort() inside libgccjit
+// takes down the child rather than the caller. The child compiles to a
+// shared library which the parent then loads.
+//
+// Go closures cannot cross a process boundary, so builds are registered by
+// name with RegisterRecipe, and the program must call ServeIsolated at the
+// start of main for the child to find them.
+
+const (
+	isolatedRecipeEnv      = "GOGCCJIT_ISOLATED_RECIPE"
+	isolatedOutputEnv      = "GOGCCJIT_ISOLATED_OUTPUT"
+	isolatedMemoryLimitEnv = "GOGCCJIT_ISOLATED_MEMORY_LIMIT"
+	isolatedErrorEnv       = "GOGCCJIT_ISOLATED_ERROR"
+)
+
+// Recipe builds the code for an isolated compilation into c. input is the
+// data given to CompileIsolated.
+type Recipe func(c *Context, input []byte) error
+
+var (
+	recipesMu sync.RWMutex
+	recipes   = map[string]Recipe{}
+)
+
+// RegisterRecipe makes recipe available to CompileIsolated as name. It is
+// meant to be called from init functions, and panics if name is taken.
+func RegisterRecipe(name string, recipe Recipe) {
+	recipesMu.Lock()
+	defer recipesMu.Unlock()
+
+	if _, ok := recipes[name]; ok {
+		panic(fmt.Sprintf("gccjit: recipe %q registered twice", name))
+	}
+
+	recipes[name] = recipe
+}
+
+func lookupRecipe(name string) (Recipe, bool) {
+	recipesMu.RLock()
+	defer recipesMu.RUnlock()
+
+	recipe, ok := recipes[name]
+
+	return recipe, ok
+}
+
+// ServeIsolated turns the process into an isolated compilation child when
+// it was started by CompileIsolated, and returns immediately otherwise. In
+// a child it never returns. Call it at the start of main, after recipes are
+// registered and before anything that should not run in the child.
+func ServeIsolated() {
+	name, ok := os.LookupEnv(isolatedRecipeEnv)
+	if !ok {
+		return
+	}
+
+	if err := serveIsolated(name); err != nil {
+		// A compile error is passed back whole, so the caller can get at it
+		// with errors.As as if the compile had run in its own process.
+		var ce *CompileError
+		if errors.As(err, &ce) {
+			if b, err := json.Marshal(ce); err == nil {
+				os.WriteFile(os.Getenv(isolatedErrorEnv), b, 0o600)
+			}
+		}
+
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	os.Exit(0)
+}
+
+func serveIsolated(name string) error {
+	if limit := os.Getenv(isolatedMemoryLimitEnv); limit != "" {
+		limitBytes, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			return fmt.Errorf("gccjit: bad memory limit %q: %w", limit, err)
+		}
+
+		if err := setMemoryLimit(limitBytes); err != nil {
+			return err
+		}
+	}
+
+	recipe, ok := lookupRecipe(name)
+	if !ok {
+		return fmt.Errorf("gccjit: unknown recipe %q", name)
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	c, err := ContextAcquireE()
+	if err != nil {
+		return err
+	}
+
+	defer c.Release()
+
+	if err := recipe(c, input); err != nil {
+		return err
+	}
+
+	return c.CompileToFileE(OUTPUT_KIND_DYNAMIC_LIBRARY, os.Getenv(isolatedOutputEnv))
+}
+
+// IsolatedOption configures CompileIsolated.
+type IsolatedOption func(*isolatedOptions)
+
+type isolatedOptions struct {
+	memoryLimit uint64
+}
+
+// WithMemoryLimit caps the address space of the child at limit bytes with
+// setrlimit. The Go runtime and libgccjit both need room, so limits below a
+// few hundred megabytes are likely to fail every compilation. Not supported
+// on Windows.
+func WithMemoryLimit(limit uint64) IsolatedOption {
+	return func(o *isolatedOptions) {
+		o.memoryLimit = limit
+	}
+}
+
+// IsolatedError reports an isolated compilation that failed, crashed or was
+// stopped.
+type IsolatedError struct {
+	Recipe string
+	// State describes how the child ended, e.g. "exit status 2" or
+	// "signal: aborted (core dumped)".
+	State  string
+	Stderr string
+	// Err is the *CompileError of the child when libgccjit rejected the
+	// code, the context error when ctx was done, and how the child exited
+	// otherwise.
+	Err error
+}
+
+func (e *IsolatedError) Error() string {
+	msg := fmt.Sprintf("gccjit: isolated compilation of %q failed: %s", e.Recipe, e.State)
+	if e.Stderr != "" {
+		msg += "\n" + e.Stderr
+	}
+
+	return msg
+}
+
+func (e *IsolatedError) Unwrap() error {
+	return e.Err
+}
+
+// IsolatedResult is the code produced by CompileIsolated, loaded into the
+// calling process as a shared library. Only exported functions and globals
+// can be looked up.
+type IsolatedResult struct {
+	mu   sync.Mutex
+	lib  uintptr
+	dir  string
+	refs *codeRefs
+}
+
+// CompileIsolated runs the recipe registered as name in a child process and
+// loads the code it compiles. The child is killed when ctx is done, which
+// also gives a timeout through context.WithTimeout. If the child fails or
+// crashes, the error is an *IsolatedError holding its stderr, which wraps a
+// *CompileError if the code did not compile.
+func CompileIsolated(ctx context.Context, name string, input []byte, opts ...IsolatedOption) (*IsolatedResult, error) {
+	// A child inherits the environment, so without this a recipe calling
+	// CompileIsolated would start a copy of itself, which would do the same.
+	if _, ok := os.LookupEnv(isolatedRecipeEnv); ok {
+		return nil, errors.New("gccjit: CompileIsolated called from an isolated child")
+	}
+
+	if _, ok := lookupRecipe(name); !ok {
+		return nil, fmt.Errorf("gccjit: unknown recipe %q", name)
+	}
+
+	var o isolatedOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "gogccjit-")
+	if err != nil {
+		return nil, err
+	}
+
+	output := filepath.Join(dir, "code"+sharedLibraryExt())
+
+	errorPath := filepath.Join(dir, "error.json")
+
+	env := append(os.Environ(), isolatedRecipeEnv+"="+name, isolatedOutputEnv+"="+output, isolatedErrorEnv+"="+errorPath)
+	if o.memoryLimit > 0 {
+		env = append(env, isolatedMemoryLimitEnv+"="+strconv.FormatUint(o.memoryLimit, 10))
+	}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		e := &IsolatedError{Recipe: name, Stderr: strings.TrimRight(stderr.String(), "\n"), Err: err}
+		if cmd.ProcessState != nil {
+			e.State = cmd.ProcessState.String()
+		} else {
+			e.State = err.Error()
+		}
+
+		if b, err := os.ReadFile(errorPath); err == nil {
+			var ce CompileError
+			if json.Unmarshal(b, &ce) == nil {
+				e.Err = &ce
+			}
+		}
+
+		if ctx.Err() != nil {
+			e.Err = ctx.Err()
+		}
+
+		os.RemoveAll(dir)
+
+		return nil, e
+	}
+
+	lib, err := loadLibrary(output)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	r := &IsolatedResult{lib: lib, dir: dir}
+	r.refs = newCodeRefs(r.free)
+
+	return r, nil
+}
+
+func sharedLibraryExt() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return ".dylib"
+	case "windows":
+		return ".dll"
+	default:
+		return ".so"
+	}
+}
+
+func (r *IsolatedResult) lookup(kind, name string) (uintptr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refs.isReleased() {
+		return 0, errors.New("gccjit: use of released IsolatedResult")
+	}
+
+	ptr, err := loadSymbol(r.lib, name)
+	if err != nil || ptr == 0 {
+		return 0, fmt.Errorf("gccjit: %s %q not found in result", kind, name)
+	}
+
+	return ptr, nil
+}
+
+// Lookup returns the address of the exported function name.
+func (r *IsolatedResult) Lookup(name string) (uintptr, error) {
+	return r.lookup("function", name)
+}
+
+// LookupGlobal returns the address of the exported global name.
+func (r *IsolatedResult) LookupGlobal(name string) (uintptr, error) {
+	return r.lookup("global", name)
+}
+
+// RegisterFunc binds the exported function name to the func fn points to.
+// The func keeps the code loaded for as long as it is reachable, so calling
+// it after Release is safe.
+func (r *IsolatedResult) RegisterFunc(name string, fn any) error {
+	ptr, err := r.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	h, ok := r.refs.hold()
+	if !ok {
+		return errors.New("gccjit: use of released IsolatedResult")
+	}
+
+	bindCode(fn, ptr, h)
+
+	return nil
+}
+
+// Release unloads the code and removes its temporary files. If funcs bound
+// by RegisterFunc are still reachable, this is put off until the last of
+// them is collected, and any error doing it then is dropped.
+func (r *IsolatedResult) Release() error {
+	_, err := r.refs.release()
+	return err
+}
+
+func (r *IsolatedResult) free() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return errors.Join(closeLibrary(r.lib), os.RemoveAll(r.dir))
+}
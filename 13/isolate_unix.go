@@ -0,0 +1,11 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package gccjit
+
+import "syscall"
+
+// setMemoryLimit caps the address space of the current process.
+func setMemoryLimit(limit uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: limit, Max: limit})
+}
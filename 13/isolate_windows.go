@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package gccjit
+
+import "fmt"
+
+// setMemoryLimit caps the address space of the current process.
+func setMemoryLimit(limit uint64) error {
+	return fmt.Errorf("gccjit: memory limits are not supported on windows")
+}
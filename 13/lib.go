@@ -1,8 +1,12 @@
 package gccjit
 
 import (
+	"errors"
 	"fmt"
-	"runtime"
+	"math"
+	"reflect"
+	"sync"
+	"unsafe"
 
 	"github.com/ebitengine/purego"
 )
@@ -83,6 +87,14 @@ type (
 	Block    struct{ Object }
 	Field    struct{ Object }
 	Struct   struct{ Type }
+	Union    struct{ Type }
+	Case     struct{ Object }
+	Vector   struct{ Type }
+
+	FunctionType struct{ Type }
+	TargetInfo   uint
+
+	ExtendedAsm struct{ Object }
 )
 
 type (
@@ -99,6 +111,14 @@ type (
 	BlockPtr    = *Block
 	FieldPtr    = *Field
 	StructPtr   = *Struct
+	UnionPtr    = *Union
+	CasePtr     = *Case
+	VectorPtr   = *Vector
+
+	FunctionTypePtr = *FunctionType
+	TargetInfoPtr   = *TargetInfo
+
+	ExtendedAsmPtr = *ExtendedAsm
 )
 
 type (
@@ -109,8 +129,13 @@ type (
 	OutputKind   int
 	Comparison   int
 	BinaryOp     int
+	UnaryOp      int
 	IntOption    int
 	GlobalKind   int
+	TLSModel     int
+
+	FnAttribute       int
+	VariableAttribute int
 )
 
 const (
@@ -204,6 +229,13 @@ const (
 	BINARY_OP_RSHIFT
 )
 
+const (
+	UNARY_OP_MINUS UnaryOp = iota
+	UNARY_OP_BITWISE_NEGATE
+	UNARY_OP_LOGICAL_NEGATE
+	UNARY_OP_ABS
+)
+
 const (
 	INT_OPTION_OPTIMIZATION_LEVEL IntOption = iota
 	NUM_INT_OPTIONS
@@ -215,6 +247,36 @@ const (
 	GLOBAL_IMPORTED
 )
 
+const (
+	TLS_MODEL_NONE TLSModel = iota
+	TLS_MODEL_GLOBAL_DYNAMIC
+	TLS_MODEL_LOCAL_DYNAMIC
+	TLS_MODEL_INITIAL_EXEC
+	TLS_MODEL_LOCAL_EXEC
+)
+
+// Function attributes, in libgccjit's order. libgccjit has no hot attribute,
+// so there is no FN_ATTRIBUTE_HOT; only FN_ATTRIBUTE_COLD can be set.
+const (
+	FN_ATTRIBUTE_ALIAS FnAttribute = iota
+	FN_ATTRIBUTE_ALWAYS_INLINE
+	FN_ATTRIBUTE_INLINE
+	FN_ATTRIBUTE_NOINLINE
+	FN_ATTRIBUTE_TARGET
+	FN_ATTRIBUTE_USED
+	FN_ATTRIBUTE_VISIBILITY
+	FN_ATTRIBUTE_COLD
+	FN_ATTRIBUTE_RETURNS_TWICE
+	FN_ATTRIBUTE_PURE
+	FN_ATTRIBUTE_CONST
+	FN_ATTRIBUTE_WEAK
+	FN_ATTRIBUTE_NONNULL
+)
+
+const (
+	VARIABLE_ATTRIBUTE_VISIBILITY VariableAttribute = iota
+)
+
 var (
 	contextAcquire                       func() *Context
 	contextRelease                       func(ctx *Context)
@@ -270,9 +332,9 @@ var (
 	lvalueGetAddress                     func(lvalue *Lvalue, loc *Location) *Rvalue
 	rvalueDereference                    func(rvalue *Rvalue, loc *Location) *Lvalue
 	typeIsBool                           func(typ *Type) bool
-	typeIsPointer                        func(typ *Type) bool
+	typeIsPointer                        func(typ *Type) *Type
 	typeIsIntegral                       func(typ *Type) bool
-	typeIsStruct                         func(typ *Type) bool
+	typeIsStruct                         func(typ *Type) *Struct
 	typeUnqualified                      func(typ *Type) *Type
 	typeGetConst                         func(typ *Type) *Type
 	typeGetVolatile                      func(typ *Type) *Type
@@ -298,318 +360,798 @@ var (
 	contextNewBitfield                   func(c *Context, l *Location, typ *Type, width int, name string) *Field
 	contextNewOpaqueStruct               func(c *Context, loc *Location, name string) *Struct
 	contextGetBuiltinFunction            func(c *Context, name string) *Function
+	contextNewBinaryOp                   func(ctx *Context, loc *Location, op BinaryOp, resultType *Type, a *Rvalue, b *Rvalue) *Rvalue
+	contextNewUnaryOp                    func(ctx *Context, loc *Location, op UnaryOp, resultType *Type, rvalue *Rvalue) *Rvalue
+	contextNewCase                       func(ctx *Context, minValue *Rvalue, maxValue *Rvalue, dest *Block) *Case
+	blockEndWithSwitch                   func(block *Block, loc *Location, expr *Rvalue, defaultBlock *Block, numCases int, cases []*Case)
+	structSetFields                      func(s *Struct, loc *Location, numFields int, fields []*Field)
+	contextNewUnionType                  func(ctx *Context, loc *Location, name string, numFields int, fields []*Field) *Union
+	globalSetInitializer                 func(global *Lvalue, blob []byte, numBytes int) *Lvalue
+	globalSetInitializerRvalue           func(global *Lvalue, initValue *Rvalue) *Lvalue
+	contextNewArrayConstructor           func(ctx *Context, loc *Location, typ *Type, numValues int, values []*Rvalue) *Rvalue
+	contextNewStructConstructor          func(ctx *Context, loc *Location, typ *Type, numValues int, fields []*Field, values []*Rvalue) *Rvalue
+	contextNewUnionConstructor           func(ctx *Context, loc *Location, typ *Type, field *Field, value *Rvalue) *Rvalue
+	typeGetVector                        func(typ *Type, numUnits int) *Type
+	typeDyncastVector                    func(typ *Type) *Vector
+	vectorTypeGetNumUnits                func(vec *Vector) uint64
+	vectorTypeGetElementType             func(vec *Vector) *Type
+	contextNewRvalueFromVector           func(ctx *Context, loc *Location, vecType *Type, numElements int, elements []*Rvalue) *Rvalue
+	blockAddExtendedAsm                  func(block *Block, loc *Location, asmTemplate string) *ExtendedAsm
+	blockEndWithExtendedAsmGoto          func(block *Block, loc *Location, asmTemplate string, numGotoBlocks int, gotoBlocks []*Block, fallthroughBlock *Block) *ExtendedAsm
+	extendedAsmSetVolatileFlag           func(ext *ExtendedAsm, flag bool)
+	extendedAsmSetInlineFlag             func(ext *ExtendedAsm, flag bool)
+	extendedAsmAddOutputOperand          func(ext *ExtendedAsm, asmSymbolicName *byte, constraint string, dest *Lvalue)
+	extendedAsmAddInputOperand           func(ext *ExtendedAsm, asmSymbolicName *byte, constraint string, src *Rvalue)
+	extendedAsmAddClobber                func(ext *ExtendedAsm, victim string)
+	contextAddTopLevelAsm                func(ctx *Context, loc *Location, asmStmts string)
+	contextNewChildContext               func(parent *Context) *Context
+	lvalueSetTLSModel                    func(lvalue *Lvalue, model TLSModel)
+	lvalueSetLinkSection                 func(lvalue *Lvalue, sectionName string)
+	lvalueSetRegisterName                func(lvalue *Lvalue, regName string)
+	lvalueSetAlignment                   func(lvalue *Lvalue, bytes uint32)
+	lvalueGetAlignment                   func(lvalue *Lvalue) uint32
+	typeGetAligned                       func(typ *Type, alignmentInBytes uint64) *Type
+	functionAddAttribute                 func(fn *Function, attribute FnAttribute)
+	functionAddStringAttribute           func(fn *Function, attribute FnAttribute, value string)
+	functionAddIntegerArrayAttribute     func(fn *Function, attribute FnAttribute, value []int32, length int)
+	lvalueAddStringAttribute             func(lvalue *Lvalue, attribute VariableAttribute, value string)
+	rvalueSetBoolRequireTailCall         func(call *Rvalue, requireTailCall bool)
+	typeDyncastArray                     func(typ *Type) *Type
+	typeDyncastFunctionPtrType           func(typ *Type) *FunctionType
+	functionTypeGetReturnType            func(fnType *FunctionType) *Type
+	functionTypeGetParamCount            func(fnType *FunctionType) uint64
+	functionTypeGetParamType             func(fnType *FunctionType, index int) *Type
+	structGetField                       func(s *Struct, index int) *Field
+	structGetFieldCount                  func(s *Struct) uint64
+	rvalueGetType                        func(rvalue *Rvalue) *Type
+	contextEnableDump                    func(ctx *Context, dumpName unsafe.Pointer, outPtr unsafe.Pointer)
+	contextSetLogfile                    func(ctx *Context, logfile unsafe.Pointer, flags int, verbosity int)
+	timerPrint                           func(t *Timer, fOut unsafe.Pointer)
+	contextNewSizeof                     func(ctx *Context, typ *Type) *Rvalue
+	contextNewAlignof                    func(ctx *Context, typ *Type) *Rvalue
+	contextGetTargetInfo                 func(ctx *Context) *TargetInfo
+	targetInfoRelease                    func(info *TargetInfo)
+	targetInfoCPUSupports                func(info *TargetInfo, feature string) bool
+	targetInfoArch                       func(info *TargetInfo) string
+	targetInfoSupportsDependentType      func(info *TargetInfo, typ Types) bool
+	contextGetIntType                    func(ctx *Context, numBytes int, isSigned bool) *Type
+	functionGetAddress                   func(fn *Function, loc *Location) *Rvalue
+	contextNewVectorAccess               func(ctx *Context, loc *Location, vector *Rvalue, index *Rvalue) *Lvalue
+	contextConvertVector                 func(ctx *Context, loc *Location, vector *Rvalue, typ *Type) *Rvalue
 )
 
-func getLibrary() string {
-	switch runtime.GOOS {
-	case "linux":
-		return "libgccjit.so.0"
-	case "darwin":
-		// FIXME: Replace hardcoded path with something else
-		return "/opt/homebrew/lib/gcc/current/libgccjit.0.dylib"
-	case "windows":
-		return "libgccjit-0.dll"
-	default:
-		panic(fmt.Errorf("GOOS=%s is not supported", runtime.GOOS))
+// libgccjit can tell an array type's element type but not its length, so
+// the lengths of arrays made by GetArrayType are kept per context.
+var (
+	arrayLengthsMu sync.Mutex
+	arrayLengths   = map[*Context]map[*Type]int{}
+)
+
+// ErrNotSupported is wrapped by the panics of methods whose entry points are
+// missing from the loaded libgccjit because it is older than the one that
+// added them. Each such feature has a probe, such as AttributesSupported, to
+// check before calling them.
+var ErrNotSupported = errors.New("not supported by the loaded libgccjit")
+
+// boundFuncs are the function variables bound by registerLibFuncs, so they
+// can be unbound again if the library turns out to be unusable.
+var boundFuncs []any
+
+// registerLibFunc is purego.RegisterLibFunc, remembering fptr in boundFuncs.
+func registerLibFunc(fptr any, lib uintptr, name string) {
+	boundFuncs = append(boundFuncs, fptr)
+	purego.RegisterLibFunc(fptr, lib, name)
+}
+
+// unbindLibFuncs sets every function variable in boundFuncs back to nil.
+func unbindLibFuncs() {
+	for _, fptr := range boundFuncs {
+		fn := reflect.ValueOf(fptr).Elem()
+		fn.Set(reflect.Zero(fn.Type()))
 	}
+
+	boundFuncs = nil
 }
 
-func init() {
-	lib, err := loadLibrary(getLibrary())
-	if err != nil {
-		panic(err)
+// registerOptionalLibFunc binds name like purego.RegisterLibFunc, but sets
+// fptr to nil instead of panicking when the library does not export it.
+func registerOptionalLibFunc(fptr any, lib uintptr, name string) {
+	boundFuncs = append(boundFuncs, fptr)
+	if sym, err := loadSymbol(lib, name); err == nil && sym != 0 {
+		purego.RegisterFunc(fptr, sym)
+	} else {
+		fn := reflect.ValueOf(fptr).Elem()
+		fn.Set(reflect.Zero(fn.Type()))
 	}
+}
 
-	purego.RegisterLibFunc(&contextAcquire, lib, "gcc_jit_context_acquire")
-	purego.RegisterLibFunc(&contextRelease, lib, "gcc_jit_context_release")
-	purego.RegisterLibFunc(&contextSetBoolOption, lib, "gcc_jit_context_set_bool_option")
-	purego.RegisterLibFunc(&contextCompile, lib, "gcc_jit_context_compile")
-	purego.RegisterLibFunc(&resultRelease, lib, "gcc_jit_result_release")
-	purego.RegisterLibFunc(&contextGetType, lib, "gcc_jit_context_get_type")
-	purego.RegisterLibFunc(&contextNewParam, lib, "gcc_jit_context_new_param")
-	purego.RegisterLibFunc(&contextNewFunction, lib, "gcc_jit_context_new_function")
-	purego.RegisterLibFunc(&contextNewStringLiteral, lib, "gcc_jit_context_new_string_literal")
-	purego.RegisterLibFunc(&functionNewBlock, lib, "gcc_jit_function_new_block")
-	purego.RegisterLibFunc(&blockAddEval, lib, "gcc_jit_block_add_eval")
-	purego.RegisterLibFunc(&contextNewCall, lib, "gcc_jit_context_new_call")
-	purego.RegisterLibFunc(&blockEndWithVoidReturn, lib, "gcc_jit_block_end_with_void_return")
-	purego.RegisterLibFunc(&resultGetCode, lib, "gcc_jit_result_get_code")
-	purego.RegisterLibFunc(&contextCompileToFile, lib, "gcc_jit_context_compile_to_file")
-	purego.RegisterLibFunc(&contextNewArrayAccess, lib, "gcc_jit_context_new_array_access")
-	purego.RegisterLibFunc(&contextNewComparison, lib, "gcc_jit_context_new_comparison")
-	purego.RegisterLibFunc(&contextNewLocation, lib, "gcc_jit_context_new_location")
-	purego.RegisterLibFunc(&blockAddComment, lib, "gcc_jit_block_add_comment")
-	purego.RegisterLibFunc(&blockAddAssignmentOp, lib, "gcc_jit_block_add_assignment_op")
-	purego.RegisterLibFunc(&contextNewCast, lib, "gcc_jit_context_new_cast")
-	purego.RegisterLibFunc(&blockAddAssignment, lib, "gcc_jit_block_add_assignment")
-	purego.RegisterLibFunc(&blockEndWithJump, lib, "gcc_jit_block_end_with_jump")
-	purego.RegisterLibFunc(&blockEndWithConditional, lib, "gcc_jit_block_end_with_conditional")
-	purego.RegisterLibFunc(&contextSetIntOption, lib, "gcc_jit_context_set_int_option")
-	purego.RegisterLibFunc(&contextNewArrayType, lib, "gcc_jit_context_new_array_type")
-	purego.RegisterLibFunc(&typeGetPointer, lib, "gcc_jit_type_get_pointer")
-	purego.RegisterLibFunc(&contextZero, lib, "gcc_jit_context_zero")
-	purego.RegisterLibFunc(&contextOne, lib, "gcc_jit_context_one")
-	purego.RegisterLibFunc(&contextNewGlobal, lib, "gcc_jit_context_new_global")
-	purego.RegisterLibFunc(&functionNewLocal, lib, "gcc_jit_function_new_local")
-	purego.RegisterLibFunc(&blockEndWithReturn, lib, "gcc_jit_block_end_with_return")
-	purego.RegisterLibFunc(&contextGetFirstError, lib, "gcc_jit_context_get_first_error")
-	purego.RegisterLibFunc(&contextGetLastError, lib, "gcc_jit_context_get_last_error")
-	purego.RegisterLibFunc(&contextDumpToFile, lib, "gcc_jit_context_dump_to_file")
-	purego.RegisterLibFunc(&contextDumpReproducerToFile, lib, "gcc_jit_context_dump_reproducer_to_file")
-	purego.RegisterLibFunc(&contextSetStrOption, lib, "gcc_jit_context_set_str_option")
-	purego.RegisterLibFunc(&contextSetBoolAllowUnreachableBlocks, lib, "gcc_jit_context_set_bool_allow_unreachable_blocks")
-	purego.RegisterLibFunc(&contextSetBoolPrintErrorsToStderr, lib, "gcc_jit_context_set_bool_print_errors_to_stderr")
-	purego.RegisterLibFunc(&contextSetBoolUseExternalDriver, lib, "gcc_jit_context_set_bool_use_external_driver")
-	purego.RegisterLibFunc(&contextAddCommandLineOption, lib, "gcc_jit_context_add_command_line_option")
-	purego.RegisterLibFunc(&contextNewField, lib, "gcc_jit_context_new_field")
-	purego.RegisterLibFunc(&contextNewStructType, lib, "gcc_jit_context_new_struct_type")
-	purego.RegisterLibFunc(&rvalueDereferenceField, lib, "gcc_jit_rvalue_dereference_field")
-	purego.RegisterLibFunc(&contextNewRvalueFromInt, lib, "gcc_jit_context_new_rvalue_from_int")
-	purego.RegisterLibFunc(&contextNewRvalueFromLong, lib, "gcc_jit_context_new_rvalue_from_long")
-	purego.RegisterLibFunc(&contextNewRvalueFromPtr, lib, "gcc_jit_context_new_rvalue_from_ptr")
-	purego.RegisterLibFunc(&contextNewFunctionPtrType, lib, "gcc_jit_context_new_function_ptr_type")
-	purego.RegisterLibFunc(&contextNewCallThroughPtr, lib, "gcc_jit_context_new_call_through_ptr")
-	purego.RegisterLibFunc(&lvalueAccessField, lib, "gcc_jit_lvalue_access_field")
-	purego.RegisterLibFunc(&contextNewBitCast, lib, "gcc_jit_context_new_bitcast")
-	purego.RegisterLibFunc(&lvalueGetAddress, lib, "gcc_jit_lvalue_get_address")
-	purego.RegisterLibFunc(&rvalueDereference, lib, "gcc_jit_rvalue_dereference")
-	purego.RegisterLibFunc(&typeIsBool, lib, "gcc_jit_type_is_bool")
-	purego.RegisterLibFunc(&typeIsPointer, lib, "gcc_jit_type_is_pointer")
-	purego.RegisterLibFunc(&typeIsIntegral, lib, "gcc_jit_type_is_integral")
-	purego.RegisterLibFunc(&typeIsStruct, lib, "gcc_jit_type_is_struct")
-	purego.RegisterLibFunc(&typeUnqualified, lib, "gcc_jit_type_unqualified")
-	purego.RegisterLibFunc(&typeGetConst, lib, "gcc_jit_type_get_const")
-	purego.RegisterLibFunc(&typeGetVolatile, lib, "gcc_jit_type_get_volatile")
-	purego.RegisterLibFunc(&typeGetSize, lib, "gcc_jit_type_get_size")
-	purego.RegisterLibFunc(&objectGetContext, lib, "gcc_jit_object_get_context")
-	purego.RegisterLibFunc(&objectGetDebugString, lib, "gcc_jit_object_get_debug_string")
-	purego.RegisterLibFunc(&timerNew, lib, "gcc_jit_timer_new")
-	purego.RegisterLibFunc(&timerRelease, lib, "gcc_jit_timer_release")
-	purego.RegisterLibFunc(&timerPush, lib, "gcc_jit_timer_push")
-	purego.RegisterLibFunc(&timerPop, lib, "gcc_jit_timer_pop")
-	purego.RegisterLibFunc(&contextSetTimer, lib, "gcc_jit_context_set_timer")
-	purego.RegisterLibFunc(&contextGetTimer, lib, "gcc_jit_context_get_timer")
-	purego.RegisterLibFunc(&versionMajor, lib, "gcc_jit_version_major")
-	purego.RegisterLibFunc(&versionMinor, lib, "gcc_jit_version_minor")
-	purego.RegisterLibFunc(&versionPatchLevel, lib, "gcc_jit_version_patchlevel")
-	purego.RegisterLibFunc(&functionGetParamCount, lib, "gcc_jit_function_get_param_count")
-	purego.RegisterLibFunc(&functionGetReturnType, lib, "gcc_jit_function_get_return_type")
-	purego.RegisterLibFunc(&functionGetParam, lib, "gcc_jit_function_get_param")
-	purego.RegisterLibFunc(&functionDumpToDot, lib, "gcc_jit_function_dump_to_dot")
-	purego.RegisterLibFunc(&contextAddDriverOption, lib, "gcc_jit_context_add_driver_option")
-	purego.RegisterLibFunc(&resultGetGlobal, lib, "gcc_jit_result_get_global")
-	purego.RegisterLibFunc(&typeCompatible, lib, "gcc_jit_compatible_types")
-	purego.RegisterLibFunc(&contextNewBitfield, lib, "gcc_jit_context_new_bitfield")
-	purego.RegisterLibFunc(&contextNewOpaqueStruct, lib, "gcc_jit_context_new_opaque_struct")
-	purego.RegisterLibFunc(&contextGetBuiltinFunction, lib, "gcc_jit_context_get_builtin_function")
+func notSupported(name string) error {
+	return fmt.Errorf("gccjit: %s (libgccjit %d.%d.%d): %w", name, versionMajor(), versionMinor(), versionPatchLevel(), ErrNotSupported)
+}
+
+// cString returns a NUL-terminated copy of s, or nil if s is empty, for
+// parameters where libgccjit treats NULL differently from "".
+func cString(s string) *byte {
+	if s == "" {
+		return nil
+	}
+
+	b := make([]byte, len(s)+1)
+	copy(b, s)
+
+	return &b[0]
+}
+
+// registerLibFuncs binds every entry point from the libgccjit at lib. Like
+// purego.RegisterLibFunc, it panics if a required one is missing.
+func registerLibFuncs(lib uintptr) {
+	loadLibc(lib)
+
+	registerLibFunc(&contextAcquire, lib, "gcc_jit_context_acquire")
+	registerLibFunc(&contextRelease, lib, "gcc_jit_context_release")
+	registerLibFunc(&contextSetBoolOption, lib, "gcc_jit_context_set_bool_option")
+	registerLibFunc(&contextCompile, lib, "gcc_jit_context_compile")
+	registerLibFunc(&resultRelease, lib, "gcc_jit_result_release")
+	registerLibFunc(&contextGetType, lib, "gcc_jit_context_get_type")
+	registerLibFunc(&contextNewParam, lib, "gcc_jit_context_new_param")
+	registerLibFunc(&contextNewFunction, lib, "gcc_jit_context_new_function")
+	registerLibFunc(&contextNewStringLiteral, lib, "gcc_jit_context_new_string_literal")
+	registerLibFunc(&functionNewBlock, lib, "gcc_jit_function_new_block")
+	registerLibFunc(&blockAddEval, lib, "gcc_jit_block_add_eval")
+	registerLibFunc(&contextNewCall, lib, "gcc_jit_context_new_call")
+	registerLibFunc(&blockEndWithVoidReturn, lib, "gcc_jit_block_end_with_void_return")
+	registerLibFunc(&resultGetCode, lib, "gcc_jit_result_get_code")
+	registerLibFunc(&contextCompileToFile, lib, "gcc_jit_context_compile_to_file")
+	registerLibFunc(&contextNewArrayAccess, lib, "gcc_jit_context_new_array_access")
+	registerLibFunc(&contextNewComparison, lib, "gcc_jit_context_new_comparison")
+	registerLibFunc(&contextNewLocation, lib, "gcc_jit_context_new_location")
+	registerLibFunc(&blockAddComment, lib, "gcc_jit_block_add_comment")
+	registerLibFunc(&blockAddAssignmentOp, lib, "gcc_jit_block_add_assignment_op")
+	registerLibFunc(&contextNewCast, lib, "gcc_jit_context_new_cast")
+	registerLibFunc(&blockAddAssignment, lib, "gcc_jit_block_add_assignment")
+	registerLibFunc(&blockEndWithJump, lib, "gcc_jit_block_end_with_jump")
+	registerLibFunc(&blockEndWithConditional, lib, "gcc_jit_block_end_with_conditional")
+	registerLibFunc(&contextSetIntOption, lib, "gcc_jit_context_set_int_option")
+	registerLibFunc(&contextNewArrayType, lib, "gcc_jit_context_new_array_type")
+	registerLibFunc(&typeGetPointer, lib, "gcc_jit_type_get_pointer")
+	registerLibFunc(&contextZero, lib, "gcc_jit_context_zero")
+	registerLibFunc(&contextOne, lib, "gcc_jit_context_one")
+	registerLibFunc(&contextNewGlobal, lib, "gcc_jit_context_new_global")
+	registerLibFunc(&functionNewLocal, lib, "gcc_jit_function_new_local")
+	registerLibFunc(&blockEndWithReturn, lib, "gcc_jit_block_end_with_return")
+	registerLibFunc(&contextGetFirstError, lib, "gcc_jit_context_get_first_error")
+	registerLibFunc(&contextGetLastError, lib, "gcc_jit_context_get_last_error")
+	registerLibFunc(&contextDumpToFile, lib, "gcc_jit_context_dump_to_file")
+	registerLibFunc(&contextDumpReproducerToFile, lib, "gcc_jit_context_dump_reproducer_to_file")
+	registerLibFunc(&contextSetStrOption, lib, "gcc_jit_context_set_str_option")
+	registerLibFunc(&contextSetBoolAllowUnreachableBlocks, lib, "gcc_jit_context_set_bool_allow_unreachable_blocks")
+	registerLibFunc(&contextSetBoolPrintErrorsToStderr, lib, "gcc_jit_context_set_bool_print_errors_to_stderr")
+	registerLibFunc(&contextSetBoolUseExternalDriver, lib, "gcc_jit_context_set_bool_use_external_driver")
+	registerLibFunc(&contextAddCommandLineOption, lib, "gcc_jit_context_add_command_line_option")
+	registerLibFunc(&contextNewField, lib, "gcc_jit_context_new_field")
+	registerLibFunc(&contextNewStructType, lib, "gcc_jit_context_new_struct_type")
+	registerLibFunc(&rvalueDereferenceField, lib, "gcc_jit_rvalue_dereference_field")
+	registerLibFunc(&contextNewRvalueFromInt, lib, "gcc_jit_context_new_rvalue_from_int")
+	registerLibFunc(&contextNewRvalueFromLong, lib, "gcc_jit_context_new_rvalue_from_long")
+	registerLibFunc(&contextNewRvalueFromPtr, lib, "gcc_jit_context_new_rvalue_from_ptr")
+	registerLibFunc(&contextNewFunctionPtrType, lib, "gcc_jit_context_new_function_ptr_type")
+	registerLibFunc(&contextNewCallThroughPtr, lib, "gcc_jit_context_new_call_through_ptr")
+	registerLibFunc(&lvalueAccessField, lib, "gcc_jit_lvalue_access_field")
+	registerLibFunc(&contextNewBitCast, lib, "gcc_jit_context_new_bitcast")
+	registerLibFunc(&lvalueGetAddress, lib, "gcc_jit_lvalue_get_address")
+	registerLibFunc(&rvalueDereference, lib, "gcc_jit_rvalue_dereference")
+	registerLibFunc(&typeIsBool, lib, "gcc_jit_type_is_bool")
+	registerLibFunc(&typeIsPointer, lib, "gcc_jit_type_is_pointer")
+	registerLibFunc(&typeIsIntegral, lib, "gcc_jit_type_is_integral")
+	registerLibFunc(&typeIsStruct, lib, "gcc_jit_type_is_struct")
+	registerLibFunc(&typeUnqualified, lib, "gcc_jit_type_unqualified")
+	registerLibFunc(&typeGetConst, lib, "gcc_jit_type_get_const")
+	registerLibFunc(&typeGetVolatile, lib, "gcc_jit_type_get_volatile")
+	registerLibFunc(&typeGetSize, lib, "gcc_jit_type_get_size")
+	registerLibFunc(&objectGetContext, lib, "gcc_jit_object_get_context")
+	registerLibFunc(&objectGetDebugString, lib, "gcc_jit_object_get_debug_string")
+	registerLibFunc(&timerNew, lib, "gcc_jit_timer_new")
+	registerLibFunc(&timerRelease, lib, "gcc_jit_timer_release")
+	registerLibFunc(&timerPush, lib, "gcc_jit_timer_push")
+	registerLibFunc(&timerPop, lib, "gcc_jit_timer_pop")
+	registerLibFunc(&contextSetTimer, lib, "gcc_jit_context_set_timer")
+	registerLibFunc(&contextGetTimer, lib, "gcc_jit_context_get_timer")
+	registerLibFunc(&versionMajor, lib, "gcc_jit_version_major")
+	registerLibFunc(&versionMinor, lib, "gcc_jit_version_minor")
+	registerLibFunc(&versionPatchLevel, lib, "gcc_jit_version_patchlevel")
+	registerLibFunc(&functionGetParamCount, lib, "gcc_jit_function_get_param_count")
+	registerLibFunc(&functionGetReturnType, lib, "gcc_jit_function_get_return_type")
+	registerLibFunc(&functionGetParam, lib, "gcc_jit_function_get_param")
+	registerLibFunc(&functionDumpToDot, lib, "gcc_jit_function_dump_to_dot")
+	registerLibFunc(&contextAddDriverOption, lib, "gcc_jit_context_add_driver_option")
+	registerLibFunc(&resultGetGlobal, lib, "gcc_jit_result_get_global")
+	registerLibFunc(&typeCompatible, lib, "gcc_jit_compatible_types")
+	registerLibFunc(&contextNewBitfield, lib, "gcc_jit_context_new_bitfield")
+	registerLibFunc(&contextNewOpaqueStruct, lib, "gcc_jit_context_new_opaque_struct")
+	registerLibFunc(&contextGetBuiltinFunction, lib, "gcc_jit_context_get_builtin_function")
+	registerLibFunc(&contextNewBinaryOp, lib, "gcc_jit_context_new_binary_op")
+	registerLibFunc(&contextNewUnaryOp, lib, "gcc_jit_context_new_unary_op")
+	registerLibFunc(&contextNewCase, lib, "gcc_jit_context_new_case")
+	registerLibFunc(&blockEndWithSwitch, lib, "gcc_jit_block_end_with_switch")
+	registerLibFunc(&structSetFields, lib, "gcc_jit_struct_set_fields")
+	registerLibFunc(&contextNewUnionType, lib, "gcc_jit_context_new_union_type")
+	registerLibFunc(&globalSetInitializer, lib, "gcc_jit_global_set_initializer")
+	registerLibFunc(&globalSetInitializerRvalue, lib, "gcc_jit_global_set_initializer_rvalue")
+	registerLibFunc(&contextNewArrayConstructor, lib, "gcc_jit_context_new_array_constructor")
+	registerLibFunc(&contextNewStructConstructor, lib, "gcc_jit_context_new_struct_constructor")
+	registerLibFunc(&contextNewUnionConstructor, lib, "gcc_jit_context_new_union_constructor")
+	registerLibFunc(&typeGetVector, lib, "gcc_jit_type_get_vector")
+	registerLibFunc(&typeDyncastVector, lib, "gcc_jit_type_dyncast_vector")
+	registerLibFunc(&vectorTypeGetNumUnits, lib, "gcc_jit_vector_type_get_num_units")
+	registerLibFunc(&vectorTypeGetElementType, lib, "gcc_jit_vector_type_get_element_type")
+	registerLibFunc(&contextNewRvalueFromVector, lib, "gcc_jit_context_new_rvalue_from_vector")
+	registerLibFunc(&blockAddExtendedAsm, lib, "gcc_jit_block_add_extended_asm")
+	registerLibFunc(&blockEndWithExtendedAsmGoto, lib, "gcc_jit_block_end_with_extended_asm_goto")
+	registerLibFunc(&extendedAsmSetVolatileFlag, lib, "gcc_jit_extended_asm_set_volatile_flag")
+	registerLibFunc(&extendedAsmSetInlineFlag, lib, "gcc_jit_extended_asm_set_inline_flag")
+	registerLibFunc(&extendedAsmAddOutputOperand, lib, "gcc_jit_extended_asm_add_output_operand")
+	registerLibFunc(&extendedAsmAddInputOperand, lib, "gcc_jit_extended_asm_add_input_operand")
+	registerLibFunc(&extendedAsmAddClobber, lib, "gcc_jit_extended_asm_add_clobber")
+	registerLibFunc(&contextAddTopLevelAsm, lib, "gcc_jit_context_add_top_level_asm")
+	registerLibFunc(&contextNewChildContext, lib, "gcc_jit_context_new_child_context")
+	registerLibFunc(&rvalueSetBoolRequireTailCall, lib, "gcc_jit_rvalue_set_bool_require_tail_call")
+	registerLibFunc(&typeDyncastArray, lib, "gcc_jit_type_dyncast_array")
+	registerLibFunc(&typeDyncastFunctionPtrType, lib, "gcc_jit_type_dyncast_function_ptr_type")
+	registerLibFunc(&functionTypeGetReturnType, lib, "gcc_jit_function_type_get_return_type")
+	registerLibFunc(&functionTypeGetParamCount, lib, "gcc_jit_function_type_get_param_count")
+	registerLibFunc(&functionTypeGetParamType, lib, "gcc_jit_function_type_get_param_type")
+	registerLibFunc(&structGetField, lib, "gcc_jit_struct_get_field")
+	registerLibFunc(&structGetFieldCount, lib, "gcc_jit_struct_get_field_count")
+	registerLibFunc(&rvalueGetType, lib, "gcc_jit_rvalue_get_type")
+	registerLibFunc(&contextEnableDump, lib, "gcc_jit_context_enable_dump")
+	registerLibFunc(&contextGetIntType, lib, "gcc_jit_context_get_int_type")
+	registerLibFunc(&functionGetAddress, lib, "gcc_jit_function_get_address")
+	registerLibFunc(&contextSetLogfile, lib, "gcc_jit_context_set_logfile")
+	registerLibFunc(&timerPrint, lib, "gcc_jit_timer_print")
+	registerLibFunc(&lvalueSetTLSModel, lib, "gcc_jit_lvalue_set_tls_model")
+	registerLibFunc(&lvalueSetLinkSection, lib, "gcc_jit_lvalue_set_link_section")
+	registerLibFunc(&lvalueSetRegisterName, lib, "gcc_jit_lvalue_set_register_name")
+	registerLibFunc(&lvalueSetAlignment, lib, "gcc_jit_lvalue_set_alignment")
+	registerLibFunc(&lvalueGetAlignment, lib, "gcc_jit_lvalue_get_alignment")
+	registerLibFunc(&typeGetAligned, lib, "gcc_jit_type_get_aligned")
+
+	// Added after libgccjit 13, bound only when the loaded library has them.
+	registerOptionalLibFunc(&functionAddAttribute, lib, "gcc_jit_function_add_attribute")
+	registerOptionalLibFunc(&functionAddStringAttribute, lib, "gcc_jit_function_add_string_attribute")
+	registerOptionalLibFunc(&functionAddIntegerArrayAttribute, lib, "gcc_jit_function_add_integer_array_attribute")
+	registerOptionalLibFunc(&lvalueAddStringAttribute, lib, "gcc_jit_lvalue_add_string_attribute")
+	registerOptionalLibFunc(&contextNewSizeof, lib, "gcc_jit_context_new_sizeof")
+	registerOptionalLibFunc(&contextNewAlignof, lib, "gcc_jit_context_new_alignof")
+	registerOptionalLibFunc(&contextGetTargetInfo, lib, "gcc_jit_context_get_target_info")
+	registerOptionalLibFunc(&targetInfoRelease, lib, "gcc_jit_target_info_release")
+	registerOptionalLibFunc(&targetInfoCPUSupports, lib, "gcc_jit_target_info_cpu_supports")
+	registerOptionalLibFunc(&targetInfoArch, lib, "gcc_jit_target_info_arch")
+	registerOptionalLibFunc(&targetInfoSupportsDependentType, lib, "gcc_jit_target_info_supports_target_dependent_type")
+	registerOptionalLibFunc(&contextNewVectorAccess, lib, "gcc_jit_context_new_vector_access")
+	registerOptionalLibFunc(&contextConvertVector, lib, "gcc_jit_context_convert_vector")
+}
+
+// AttributesSupported reports whether the loaded libgccjit has the function
+// and variable attribute API, added in libgccjit 14.
+func AttributesSupported() bool {
+	return Available() && functionAddAttribute != nil
+}
+
+// SizeofSupported reports whether the loaded libgccjit has NewSizeof, added
+// in libgccjit 14.
+func SizeofSupported() bool {
+	return Available() && contextNewSizeof != nil
+}
+
+// AlignofSupported reports whether the loaded libgccjit has NewAlignof, added
+// in libgccjit 14.
+func AlignofSupported() bool {
+	return Available() && contextNewAlignof != nil
+}
+
+// TargetInfoSupported reports whether the loaded libgccjit has GetTargetInfo,
+// added in libgccjit 15.
+func TargetInfoSupported() bool {
+	return Available() && contextGetTargetInfo != nil
+}
+
+// VectorAccessSupported reports whether the loaded libgccjit has
+// NewVectorAccess, added in libgccjit 15.
+func VectorAccessSupported() bool {
+	return Available() && contextNewVectorAccess != nil
+}
+
+// ConvertVectorSupported reports whether the loaded libgccjit has
+// ConvertVector, added in libgccjit 15.
+func ConvertVectorSupported() bool {
+	return Available() && contextConvertVector != nil
 }
 
 func VersionMajor() int {
+	mustLoad()
 	return versionMajor()
 }
 
 func VersionMinor() int {
+	mustLoad()
 	return versionMinor()
 }
 
 func VersionPatchLevel() int {
+	mustLoad()
 	return versionPatchLevel()
 }
 
 func TimerNew() *Timer {
+	mustLoad()
 	return timerNew()
 }
 
 func (t *Timer) Release() {
 	timerRelease(t)
+	forgetTimerItems(t)
 }
 
 func (t *Timer) Push(name string) {
 	timerPush(t, name)
+	pushTimerItem(t, name)
 }
 
 func (t *Timer) Pop(name string) {
 	timerPop(t, name)
+	popTimerItem(t)
 }
 
+// ContextAcquire returns a new top-level context, loading libgccjit first if
+// needed. It returns nil if libgccjit cannot be loaded, and methods called on
+// that nil Context panic with the load error; use ContextAcquireE to handle
+// the failure instead.
 func ContextAcquire() *Context {
-	return contextAcquire()
+	c, _ := ContextAcquireE()
+	return c
+}
+
+// ContextAcquireE is ContextAcquire, returning why no context could be
+// created instead of nil.
+func ContextAcquireE() (*Context, error) {
+	if err := Load(); err != nil {
+		return nil, err
+	}
+
+	c := contextAcquire()
+	if c == nil {
+		return nil, errors.New("gccjit: failed to acquire context")
+	}
+
+	trackContext(c, nil)
+
+	return c, nil
+}
+
+// NewChild creates a context that inherits the options of c and can use the
+// types, functions and structs created in c. Compiling or releasing the child
+// leaves c untouched, but every child must be released before c is.
+func (c *Context) NewChild() *Context {
+	c.mustBeLive()
+	child := contextNewChildContext(c)
+	if child != nil {
+		trackContext(child, c)
+		inheritLogger(child, c)
+	}
+
+	return child
+}
+
+func (t *TargetInfo) Release() {
+	targetInfoRelease(t)
+}
+
+// CPUSupports reports whether the target CPU has feature, such as "avx2",
+// with the names accepted by __builtin_cpu_supports.
+func (t *TargetInfo) CPUSupports(feature string) bool {
+	return targetInfoCPUSupports(t, feature)
+}
+
+// Arch returns the CPU the target is tuned for, as with -march.
+func (t *TargetInfo) Arch() string {
+	return targetInfoArch(t)
+}
+
+// SupportsTargetDependentType reports whether typ, such as TYPE_INT128_T, is
+// available on the target.
+func (t *TargetInfo) SupportsTargetDependentType(typ Types) bool {
+	return targetInfoSupportsDependentType(t, typ)
 }
 
 func (o *Object) GetContext() *Context {
+	mustBeLiveObject(o)
 	return objectGetContext(o)
 }
 
 func (o *Object) GetDebugString() string {
+	mustBeLiveObject(o)
 	return objectGetDebugString(o)
 }
 
 func (c *Context) SetTimer(t *Timer) {
+	c.mustBeLive()
 	contextSetTimer(c, t)
 }
 
 func (c *Context) GetTimer() *Timer {
+	c.mustBeLive()
 	return contextGetTimer(c)
 }
 
 func (c *Context) SetBoolOption(opt BoolOption, value bool) {
+	c.mustBeLive()
 	contextSetBoolOption(c, opt, value)
 }
 
 func (c *Context) SetIntOption(opt IntOption, value int) {
+	c.mustBeLive()
 	contextSetIntOption(c, opt, value)
 }
 
 func (c *Context) SetStrOption(opt StrOption, value string) {
+	c.mustBeLive()
 	contextSetStrOption(c, opt, value)
 }
 
 func (c *Context) SetBoolAllowUnreachableBlocks(value bool) {
+	c.mustBeLive()
 	contextSetBoolAllowUnreachableBlocks(c, value)
 }
 
 func (c *Context) SetBoolPrintErrorsToStderr(value bool) {
+	c.mustBeLive()
 	contextSetBoolPrintErrorsToStderr(c, value)
 }
 
 func (c *Context) SetBoolUseExternalDriver(value bool) {
+	c.mustBeLive()
 	contextSetBoolUseExternalDriver(c, value)
 }
 
 func (c *Context) AddCommandLineOption(optname string) {
+	c.mustBeLive()
 	contextAddCommandLineOption(c, optname)
 }
 
 func (c *Context) AddDriverOption(optname string) {
+	c.mustBeLive()
 	contextAddDriverOption(c, optname)
 }
 
 func (c *Context) GetBuiltinFunction(name string) *Function {
-	return contextGetBuiltinFunction(c, name)
+	c.mustBeLive()
+	return ownShared(contextGetBuiltinFunction(c, name))
 }
 
 func (c *Context) NewBitfield(loc *Location, typ *Type, width int, name string) *Field {
-	return contextNewBitfield(c, loc, typ, width, name)
+	c.mustBeLive()
+	return own(c, contextNewBitfield(c, loc, typ, width, name))
 }
 
 func (c *Context) GetType(typ Types) *Type {
-	return contextGetType(c, typ)
+	c.mustBeLive()
+	return ownShared(contextGetType(c, typ))
+}
+
+// GetIntType returns the integer type that is numBytes wide on the target.
+func (c *Context) GetIntType(numBytes int, signed bool) *Type {
+	c.mustBeLive()
+	return ownShared(contextGetIntType(c, numBytes, signed))
 }
 
 func (c *Context) GetArrayType(loc *Location, elementType *Type, numElements int) *Type {
-	return contextNewArrayType(c, loc, elementType, numElements)
+	c.mustBeLive()
+	typ := own(c, contextNewArrayType(c, loc, elementType, numElements))
+	if typ == nil {
+		return nil
+	}
+
+	arrayLengthsMu.Lock()
+	defer arrayLengthsMu.Unlock()
+
+	if arrayLengths[c] == nil {
+		arrayLengths[c] = map[*Type]int{}
+	}
+
+	arrayLengths[c][typ] = numElements
+
+	return typ
 }
 
 func (c *Context) NewFunctionPtrType(loc *Location, returnType *Type, paramTypes []*Type, isVariadic bool) *Type {
-	return contextNewFunctionPtrType(c, loc, returnType, len(paramTypes), paramTypes, isVariadic)
+	c.mustBeLive()
+	return own(c, contextNewFunctionPtrType(c, loc, returnType, len(paramTypes), paramTypes, isVariadic))
 }
 
 func (c *Context) NewOpaqueStruct(loc *Location, name string) *Struct {
-	return contextNewOpaqueStruct(c, loc, name)
+	c.mustBeLive()
+	return own(c, contextNewOpaqueStruct(c, loc, name))
 }
 
 func (c *Context) NewStructType(loc *Location, name string, fields []*Field) *Struct {
-	return contextNewStructType(c, loc, name, len(fields), fields)
+	c.mustBeLive()
+	return own(c, contextNewStructType(c, loc, name, len(fields), fields))
+}
+
+func (c *Context) NewUnionType(loc *Location, name string, fields []*Field) *Union {
+	c.mustBeLive()
+	return own(c, contextNewUnionType(c, loc, name, len(fields), fields))
 }
 
 func (c *Context) NewFunction(loc *Location, kind FunctionKind, return_type *Type, name string, params []*Param, isVariadic bool) *Function {
-	return contextNewFunction(c, loc, kind, return_type, name, len(params), params, isVariadic)
+	c.mustBeLive()
+	return own(c, contextNewFunction(c, loc, kind, return_type, name, len(params), params, isVariadic))
 }
 
 func (c *Context) NewParam(loc *Location, typ *Type, name string) *Param {
-	return contextNewParam(c, loc, typ, name)
+	c.mustBeLive()
+	return own(c, contextNewParam(c, loc, typ, name))
 }
 
 func (c *Context) NewBlock(fn *Function, name string) *Block {
-	return functionNewBlock(fn, name)
+	c.mustBeLive()
+	return own(c, functionNewBlock(fn, name))
 }
 
 func (c *Context) NewCall(loc *Location, fn *Function, args []*Rvalue) *Rvalue {
-	return contextNewCall(c, loc, fn, len(args), args)
+	c.mustBeLive()
+	return own(c, contextNewCall(c, loc, fn, len(args), args))
 }
 
 func (c *Context) NewCallThroughPtr(loc *Location, ptr *Rvalue, args []*Rvalue) *Rvalue {
-	return contextNewCallThroughPtr(c, loc, ptr, len(args), args)
+	c.mustBeLive()
+	return own(c, contextNewCallThroughPtr(c, loc, ptr, len(args), args))
 }
 
 func (c *Context) NewStringLiteral(value string) *Rvalue {
-	return contextNewStringLiteral(c, value)
+	c.mustBeLive()
+	return own(c, contextNewStringLiteral(c, value))
 }
 
 func (c *Context) NewArrayAccess(loc *Location, ptr *Rvalue, idx *Rvalue) *Lvalue {
-	return contextNewArrayAccess(c, loc, ptr, idx)
+	c.mustBeLive()
+	return own(c, contextNewArrayAccess(c, loc, ptr, idx))
 }
 
 func (c *Context) NewNewComparison(loc *Location, op Comparison, lhs *Rvalue, rhs *Rvalue) *Rvalue {
-	return contextNewComparison(c, loc, op, lhs, rhs)
+	c.mustBeLive()
+	return own(c, contextNewComparison(c, loc, op, lhs, rhs))
+}
+
+func (c *Context) NewBinaryOp(loc *Location, op BinaryOp, resultType *Type, a *Rvalue, b *Rvalue) *Rvalue {
+	c.mustBeLive()
+	return own(c, contextNewBinaryOp(c, loc, op, resultType, a, b))
+}
+
+func (c *Context) NewUnaryOp(loc *Location, op UnaryOp, resultType *Type, rvalue *Rvalue) *Rvalue {
+	c.mustBeLive()
+	return own(c, contextNewUnaryOp(c, loc, op, resultType, rvalue))
+}
+
+func (c *Context) NewCase(min *Rvalue, max *Rvalue, dest *Block) *Case {
+	c.mustBeLive()
+	return own(c, contextNewCase(c, min, max, dest))
 }
 
 func (c *Context) NewLocation(filename string, line, column int) *Location {
-	return contextNewLocation(c, filename, line, column)
+	c.mustBeLive()
+	return own(c, contextNewLocation(c, filename, line, column))
 }
 
 func (c *Context) NewCast(loc *Location, rvalue *Rvalue, typ *Type) *Rvalue {
-	return contextNewCast(c, loc, rvalue, typ)
+	c.mustBeLive()
+	return own(c, contextNewCast(c, loc, rvalue, typ))
 }
 
 func (c *Context) NewBitCast(loc *Location, rvalue *Rvalue, typ *Type) *Rvalue {
-	return contextNewBitCast(c, loc, rvalue, typ)
+	c.mustBeLive()
+	return own(c, contextNewBitCast(c, loc, rvalue, typ))
 }
 
 func (c *Context) NewGlobal(loc *Location, kind GlobalKind, typ *Type, name string) *Lvalue {
-	return contextNewGlobal(c, loc, kind, typ, name)
+	c.mustBeLive()
+	global := own(c, contextNewGlobal(c, loc, kind, typ, name))
+	if global != nil {
+		recordGlobal(c, name, typ)
+	}
+
+	return global
 }
 
 func (c *Context) NewRValueFromInt(typ *Type, value int) *Rvalue {
-	return contextNewRvalueFromInt(c, typ, value)
+	c.mustBeLive()
+	return own(c, contextNewRvalueFromInt(c, typ, value))
 }
 
 func (c *Context) NewRValueFromLong(typ *Type, value int64) *Rvalue {
-	return contextNewRvalueFromLong(c, typ, value)
+	c.mustBeLive()
+	return own(c, contextNewRvalueFromLong(c, typ, value))
+}
+
+// NewRvalueFromDouble builds a floating-point constant of typ. purego cannot
+// pass floating-point arguments when built with cgo on Linux, so rather than
+// calling gcc_jit_context_new_rvalue_from_double, the value is built from
+// its exact bit pattern and bitcast to float or double.
+func (c *Context) NewRvalueFromDouble(typ *Type, value float64) *Rvalue {
+	c.mustBeLive()
+
+	switch typ.Unqualified() {
+	case c.GetType(TYPE_FLOAT):
+		bits := c.NewRValueFromLong(c.GetType(TYPE_UINT32_T), int64(math.Float32bits(float32(value))))
+		return c.NewBitCast(nil, bits, typ)
+	case c.GetType(TYPE_DOUBLE):
+		bits := c.NewRValueFromLong(c.GetType(TYPE_UINT64_T), int64(math.Float64bits(value)))
+		return c.NewBitCast(nil, bits, typ)
+	default:
+		// long double is wider than any integer constant; widening from
+		// double is exact.
+		return c.NewCast(nil, c.NewRvalueFromDouble(c.GetType(TYPE_DOUBLE), value), typ)
+	}
 }
 
 func (c *Context) NewRvalueFromPtr(typ *Type, value uintptr) *Rvalue {
-	return contextNewRvalueFromPtr(c, typ, value)
+	c.mustBeLive()
+	return own(c, contextNewRvalueFromPtr(c, typ, value))
+}
+
+func (c *Context) NewArrayConstructor(loc *Location, typ *Type, values []*Rvalue) *Rvalue {
+	c.mustBeLive()
+	return own(c, contextNewArrayConstructor(c, loc, typ, len(values), values))
+}
+
+// NewStructConstructor builds a struct value. If fields is nil, values are
+// assigned to the fields of typ in declaration order.
+func (c *Context) NewStructConstructor(loc *Location, typ *Type, fields []*Field, values []*Rvalue) *Rvalue {
+	c.mustBeLive()
+	return own(c, contextNewStructConstructor(c, loc, typ, len(values), fields, values))
+}
+
+func (c *Context) NewUnionConstructor(loc *Location, typ *Type, field *Field, value *Rvalue) *Rvalue {
+	c.mustBeLive()
+	return own(c, contextNewUnionConstructor(c, loc, typ, field, value))
+}
+
+func (c *Context) NewRvalueFromVector(loc *Location, vecType *Type, elements []*Rvalue) *Rvalue {
+	c.mustBeLive()
+	return own(c, contextNewRvalueFromVector(c, loc, vecType, len(elements), elements))
+}
+
+// NewVectorAccess returns element index of vector. It needs libgccjit 15 or
+// newer and panics with ErrNotSupported unless VectorAccessSupported.
+func (c *Context) NewVectorAccess(loc *Location, vector *Rvalue, index *Rvalue) *Lvalue {
+	c.mustBeLive()
+	if contextNewVectorAccess == nil {
+		panic(notSupported("gcc_jit_context_new_vector_access"))
+	}
+
+	return own(c, contextNewVectorAccess(c, loc, vector, index))
+}
+
+// ConvertVector converts each element of vector to the element type of the
+// vector type typ, like __builtin_convertvector. It needs libgccjit 15 or
+// newer and panics with ErrNotSupported unless ConvertVectorSupported.
+func (c *Context) ConvertVector(loc *Location, vector *Rvalue, typ *Type) *Rvalue {
+	c.mustBeLive()
+	if contextConvertVector == nil {
+		panic(notSupported("gcc_jit_context_convert_vector"))
+	}
+
+	return own(c, contextConvertVector(c, loc, vector, typ))
+}
+
+// NewSizeof returns sizeof(typ) as a size_t rvalue. It needs libgccjit 14 or
+// newer and panics with ErrNotSupported unless SizeofSupported.
+func (c *Context) NewSizeof(typ *Type) *Rvalue {
+	c.mustBeLive()
+	if contextNewSizeof == nil {
+		panic(notSupported("gcc_jit_context_new_sizeof"))
+	}
+
+	return own(c, contextNewSizeof(c, typ))
+}
+
+// NewAlignof returns _Alignof(typ) as an int rvalue. It needs libgccjit 14
+// or newer and panics with ErrNotSupported unless AlignofSupported.
+func (c *Context) NewAlignof(typ *Type) *Rvalue {
+	c.mustBeLive()
+	if contextNewAlignof == nil {
+		panic(notSupported("gcc_jit_context_new_alignof"))
+	}
+
+	return own(c, contextNewAlignof(c, typ))
+}
+
+// GetTargetInfo describes the target c compiles for, as set up by its
+// options. Gathering it runs part of the compiler, so callers should keep the
+// result rather than asking again. It needs libgccjit 15 or newer and panics
+// with ErrNotSupported unless TargetInfoSupported.
+func (c *Context) GetTargetInfo() *TargetInfo {
+	c.mustBeLive()
+	if contextGetTargetInfo == nil {
+		panic(notSupported("gcc_jit_context_get_target_info"))
+	}
+
+	return contextGetTargetInfo(c)
 }
 
 func (c *Context) NewField(loc *Location, typ *Type, name string) *Field {
-	return contextNewField(c, loc, typ, name)
+	c.mustBeLive()
+	return own(c, contextNewField(c, loc, typ, name))
 }
 
 func (c *Context) Zero(typ *Type) *Rvalue {
-	return contextZero(c, typ)
+	c.mustBeLive()
+	return own(c, contextZero(c, typ))
 }
 
 func (c *Context) One(typ *Type) *Rvalue {
-	return contextOne(c, typ)
+	c.mustBeLive()
+	return own(c, contextOne(c, typ))
 }
 
 func (c *Context) DumpToFile(path string, updateLocations bool) {
+	c.mustBeLive()
 	contextDumpToFile(c, path, updateLocations)
 }
 
 func (c *Context) DumpReproducerToFile(path string) {
+	c.mustBeLive()
 	contextDumpReproducerToFile(c, path)
 }
 
+func (c *Context) AddTopLevelAsm(loc *Location, asmStmts string) {
+	c.mustBeLive()
+	contextAddTopLevelAsm(c, loc, asmStmts)
+}
+
 func (c *Context) Compile() *Result {
-	return contextCompile(c)
+	c.mustBeLive()
+	r := contextCompile(c)
+	collectDumps(c)
+	if r != nil {
+		trackResult(r)
+		recordResult(c, r)
+		holdLogger(c, r)
+	}
+
+	return r
 }
 
 func (c *Context) GetFirstError() string {
+	c.mustBeLive()
 	return contextGetFirstError(c)
 }
 
 func (c *Context) GetLastError() string {
+	c.mustBeLive()
 	return contextGetLastError(c)
 }
 
 func (c *Context) CompileToFile(outputKind OutputKind, outputPath string) {
+	c.mustBeLive()
 	contextCompileToFile(c, outputKind, outputPath)
+	collectDumps(c)
 }
 
+// Release frees c and everything made from it. Child contexts must be
+// released first. Results compiled from c stay valid, and keep the logger
+// set with SetLogger open until they are released too. A context that is
+// never released is never freed; see Result.Release.
 func (c *Context) Release() {
+	untrackContext(c)
 	contextRelease(c)
+	releaseDumps(c)
+	releaseLogger(c)
+	forgetContextGlobals(c)
+
+	arrayLengthsMu.Lock()
+	delete(arrayLengths, c)
+	arrayLengthsMu.Unlock()
 }
 
 func (p *Param) AsRvalue() *Rvalue {
@@ -617,56 +1159,172 @@ func (p *Param) AsRvalue() *Rvalue {
 }
 
 func (b *Block) AddEval(loc *Location, rvalue *Rvalue) {
+	mustBeLiveObject(b)
 	blockAddEval(b, loc, rvalue)
 }
 
 func (b *Block) EndWithVoidReturn(loc *Location) {
+	mustBeLiveObject(b)
 	blockEndWithVoidReturn(b, loc)
 }
 
 func (b *Block) AddComment(loc *Location, text string) {
+	mustBeLiveObject(b)
 	blockAddComment(b, loc, text)
 }
 
 func (b *Block) AddAssignmentOp(loc *Location, lvalue *Lvalue, op BinaryOp, rvalue *Rvalue) {
+	mustBeLiveObject(b)
 	blockAddAssignmentOp(b, loc, lvalue, op, rvalue)
 }
 
 func (b *Block) AddAssignment(loc *Location, lvalue *Lvalue, rvalue *Rvalue) {
+	mustBeLiveObject(b)
 	blockAddAssignment(b, loc, lvalue, rvalue)
 }
 
 func (b *Block) EndWithJump(loc *Location, target *Block) {
+	mustBeLiveObject(b)
 	blockEndWithJump(b, loc, target)
 }
 
 func (b *Block) EndWithConditional(loc *Location, boolval *Rvalue, onTrue *Block, on_false *Block) {
+	mustBeLiveObject(b)
 	blockEndWithConditional(b, loc, boolval, onTrue, on_false)
 }
 
+func (b *Block) EndWithSwitch(loc *Location, expr *Rvalue, defaultBlock *Block, cases []*Case) {
+	mustBeLiveObject(b)
+	blockEndWithSwitch(b, loc, expr, defaultBlock, len(cases), cases)
+}
+
 func (b *Block) EndWithReturn(loc *Location, rvalue *Rvalue) {
+	mustBeLiveObject(b)
 	blockEndWithReturn(b, loc, rvalue)
 }
 
+func (b *Block) AddExtendedAsm(loc *Location, asmTemplate string) *ExtendedAsm {
+	owner := mustBeLiveObject(b)
+	return own(owner, blockAddExtendedAsm(b, loc, asmTemplate))
+}
+
+// EndWithExtendedAsmGoto terminates b with an "asm goto" that may jump to any
+// of gotoBlocks, continuing at fallthroughBlock otherwise.
+func (b *Block) EndWithExtendedAsmGoto(loc *Location, asmTemplate string, gotoBlocks []*Block, fallthroughBlock *Block) *ExtendedAsm {
+	owner := mustBeLiveObject(b)
+	return own(owner, blockEndWithExtendedAsmGoto(b, loc, asmTemplate, len(gotoBlocks), gotoBlocks, fallthroughBlock))
+}
+
+func (e *ExtendedAsm) SetVolatileFlag(flag bool) {
+	mustBeLiveObject(e)
+	extendedAsmSetVolatileFlag(e, flag)
+}
+
+func (e *ExtendedAsm) SetInlineFlag(flag bool) {
+	mustBeLiveObject(e)
+	extendedAsmSetInlineFlag(e, flag)
+}
+
+// AddOutputOperand adds an output operand. asmSymbolicName may be empty for
+// operands referred to by position.
+func (e *ExtendedAsm) AddOutputOperand(asmSymbolicName string, constraint string, dest *Lvalue) {
+	mustBeLiveObject(e)
+	extendedAsmAddOutputOperand(e, cString(asmSymbolicName), constraint, dest)
+}
+
+// AddInputOperand adds an input operand. asmSymbolicName may be empty for
+// operands referred to by position.
+func (e *ExtendedAsm) AddInputOperand(asmSymbolicName string, constraint string, src *Rvalue) {
+	mustBeLiveObject(e)
+	extendedAsmAddInputOperand(e, cString(asmSymbolicName), constraint, src)
+}
+
+func (e *ExtendedAsm) AddClobber(victim string) {
+	mustBeLiveObject(e)
+	extendedAsmAddClobber(e, victim)
+}
+
 func (r *Result) GetGlobal(name string) uintptr {
+	r.mustBeLive()
 	return resultGetGlobal(r, name)
 }
 
 func (r *Result) GetCode(name string) uintptr {
+	r.mustBeLive()
 	return resultGetCode(r, name)
 }
 
+// RegisterFunc binds the compiled function name to the func fn points to.
+// The bound func keeps r loaded while it is reachable, even after Release.
 func (r *Result) RegisterFunc(name string, fn any) {
 	ptr := r.GetCode(name)
-	purego.RegisterFunc(fn, ptr)
+	bindFunc(r, fn, ptr)
 }
 
+// Release frees r once no func bound to it is reachable any more. Using r
+// itself after Release panics. A Result is not freed unless Release is
+// called: it is a pointer into C memory, which the garbage collector cannot
+// attach a finalizer to.
 func (r *Result) Release() {
-	resultRelease(r)
+	releaseResult(r)
 }
 
 func (l *Lvalue) GetAddress(loc *Location) *Rvalue {
-	return lvalueGetAddress(l, loc)
+	owner := mustBeLiveObject(l)
+	return own(owner, lvalueGetAddress(l, loc))
+}
+
+// SetInitializer initializes a global with a copy of blob. The size of blob
+// must match the size of the global's type.
+func (l *Lvalue) SetInitializer(blob []byte) *Lvalue {
+	owner := mustBeLiveObject(l)
+	return own(owner, globalSetInitializer(l, blob, len(blob)))
+}
+
+func (l *Lvalue) SetInitializerRvalue(value *Rvalue) *Lvalue {
+	owner := mustBeLiveObject(l)
+	return own(owner, globalSetInitializerRvalue(l, value))
+}
+
+func (l *Lvalue) SetTLSModel(model TLSModel) {
+	mustBeLiveObject(l)
+	lvalueSetTLSModel(l, model)
+}
+
+// SetLinkSection places a global in sectionName, like
+// __attribute__((section(".name"))).
+func (l *Lvalue) SetLinkSection(sectionName string) {
+	mustBeLiveObject(l)
+	lvalueSetLinkSection(l, sectionName)
+}
+
+// SetRegisterName pins a local or global to a hard register, like
+// "register int x asm("reg")".
+func (l *Lvalue) SetRegisterName(regName string) {
+	mustBeLiveObject(l)
+	lvalueSetRegisterName(l, regName)
+}
+
+func (l *Lvalue) SetAlignment(bytes uint32) {
+	mustBeLiveObject(l)
+	lvalueSetAlignment(l, bytes)
+}
+
+func (l *Lvalue) GetAlignment() uint32 {
+	mustBeLiveObject(l)
+	return lvalueGetAlignment(l)
+}
+
+// AddStringAttribute adds an attribute taking a string, such as
+// VARIABLE_ATTRIBUTE_VISIBILITY with "hidden". It panics with
+// ErrNotSupported unless AttributesSupported.
+func (l *Lvalue) AddStringAttribute(attribute VariableAttribute, value string) {
+	mustBeLiveObject(l)
+	if lvalueAddStringAttribute == nil {
+		panic(notSupported("gcc_jit_lvalue_add_string_attribute"))
+	}
+
+	lvalueAddStringAttribute(l, attribute, value)
 }
 
 func (l *Lvalue) AsRvalue() *Rvalue {
@@ -674,81 +1332,268 @@ func (l *Lvalue) AsRvalue() *Rvalue {
 }
 
 func (l *Lvalue) AccessField(loc *Location, field *Field) *Lvalue {
-	return lvalueAccessField(l, loc, field)
+	owner := mustBeLiveObject(l)
+	return own(owner, lvalueAccessField(l, loc, field))
 }
 
 func (r *Rvalue) DereferenceField(loc *Location, field *Field) *Lvalue {
-	return rvalueDereferenceField(r, loc, field)
+	owner := mustBeLiveObject(r)
+	return own(owner, rvalueDereferenceField(r, loc, field))
+}
+
+// RequireTailCall marks a call made by NewCall or NewCallThroughPtr as one
+// that must be compiled as a tail call. If GCC cannot honour it, compilation
+// fails and GetFirstError reports "cannot tail-call" with the reason.
+func (r *Rvalue) RequireTailCall(require bool) {
+	mustBeLiveObject(r)
+	rvalueSetBoolRequireTailCall(r, require)
+}
+
+func (r *Rvalue) GetType() *Type {
+	owner := mustBeLiveObject(r)
+	return own(owner, rvalueGetType(r))
 }
 
 func (r *Rvalue) Dereference(loc *Location) *Lvalue {
-	return rvalueDereference(r, loc)
+	owner := mustBeLiveObject(r)
+	return own(owner, rvalueDereference(r, loc))
 }
 
 func (f *Function) NewBlock(name string) *Block {
-	return functionNewBlock(f, name)
+	owner := mustBeLiveObject(f)
+	return own(owner, functionNewBlock(f, name))
 }
 
 func (f *Function) NewLocal(loc *Location, typ *Type, name string) *Lvalue {
-	return functionNewLocal(f, loc, typ, name)
+	owner := mustBeLiveObject(f)
+	return own(owner, functionNewLocal(f, loc, typ, name))
 }
 
 func (f *Function) GetParamCount() uint64 {
+	mustBeLiveObject(f)
 	return functionGetParamCount(f)
 }
 
 func (f *Function) GetReturnType() *Type {
-	return functionGetReturnType(f)
+	owner := mustBeLiveObject(f)
+	return own(owner, functionGetReturnType(f))
 }
 
 func (f *Function) GetParam(index int) *Param {
-	return functionGetParam(f, index)
+	owner := mustBeLiveObject(f)
+	return own(owner, functionGetParam(f, index))
+}
+
+// AddAttribute adds an attribute without arguments, such as
+// FN_ATTRIBUTE_NOINLINE or FN_ATTRIBUTE_COLD (libgccjit has no hot
+// counterpart). Like the other attribute methods it panics with
+// ErrNotSupported unless AttributesSupported.
+func (f *Function) AddAttribute(attribute FnAttribute) {
+	mustBeLiveObject(f)
+	if functionAddAttribute == nil {
+		panic(notSupported("gcc_jit_function_add_attribute"))
+	}
+
+	functionAddAttribute(f, attribute)
+}
+
+// AddStringAttribute adds an attribute taking a string, such as
+// FN_ATTRIBUTE_TARGET with "avx2" or FN_ATTRIBUTE_VISIBILITY with "hidden".
+func (f *Function) AddStringAttribute(attribute FnAttribute, value string) {
+	mustBeLiveObject(f)
+	if functionAddStringAttribute == nil {
+		panic(notSupported("gcc_jit_function_add_string_attribute"))
+	}
+
+	functionAddStringAttribute(f, attribute, value)
+}
+
+// AddIntArrayAttribute adds an attribute taking a list of integers, such as
+// FN_ATTRIBUTE_NONNULL with 1-based parameter indexes.
+func (f *Function) AddIntArrayAttribute(attribute FnAttribute, values []int32) {
+	mustBeLiveObject(f)
+	if functionAddIntegerArrayAttribute == nil {
+		panic(notSupported("gcc_jit_function_add_integer_array_attribute"))
+	}
+
+	functionAddIntegerArrayAttribute(f, attribute, values, len(values))
+}
+
+// GetAddress returns a pointer to f. Its type is the one NewFunctionPtrType
+// gives for f's signature, so it can be stored in fields and globals of that
+// type or called through with NewCallThroughPtr.
+func (f *Function) GetAddress(loc *Location) *Rvalue {
+	owner := mustBeLiveObject(f)
+	return own(owner, functionGetAddress(f, loc))
 }
 
 func (f *Function) DumpToDot(path string) {
+	mustBeLiveObject(f)
 	functionDumpToDot(f, path)
 }
 
 func (t *Type) IsCompatible(target *Type) bool {
+	mustBeLiveObject(t)
 	return typeCompatible(t, target)
 }
 
 func (t *Type) GetPointer() *Type {
-	return typeGetPointer(t)
+	owner := mustBeLiveObject(t)
+	return own(owner, typeGetPointer(t))
 }
 
 func (t *Type) GetConst() *Type {
-	return typeGetConst(t)
+	owner := mustBeLiveObject(t)
+	return own(owner, typeGetConst(t))
 }
 
 func (t *Type) GetVolatile() *Type {
-	return typeGetVolatile(t)
+	owner := mustBeLiveObject(t)
+	return own(owner, typeGetVolatile(t))
+}
+
+func (t *Type) GetAligned(alignmentInBytes uint64) *Type {
+	owner := mustBeLiveObject(t)
+	return own(owner, typeGetAligned(t, alignmentInBytes))
 }
 
 func (t *Type) GetSize() uint64 {
+	mustBeLiveObject(t)
 	return typeGetSize(t)
 }
 
 func (t *Type) IsBool() bool {
+	mustBeLiveObject(t)
 	return typeIsBool(t)
 }
 
 func (t *Type) IsPointer() bool {
-	return typeIsPointer(t)
+	mustBeLiveObject(t)
+	return typeIsPointer(t) != nil
+}
+
+// GetPointee returns the type t points to, or nil if t is not a pointer.
+func (t *Type) GetPointee() *Type {
+	owner := mustBeLiveObject(t)
+	return own(owner, typeIsPointer(t))
+}
+
+// DyncastArray returns the element type of t, or nil if t is not an array.
+func (t *Type) DyncastArray() *Type {
+	owner := mustBeLiveObject(t)
+	return own(owner, typeDyncastArray(t))
+}
+
+// GetArrayLength returns the number of elements of an array type made by
+// Context.GetArrayType. It reports false for other types, including
+// qualified variants of such an array type.
+func (t *Type) GetArrayLength() (int, bool) {
+	mustBeLiveObject(t)
+
+	arrayLengthsMu.Lock()
+	defer arrayLengthsMu.Unlock()
+
+	n, ok := arrayLengths[t.GetContext()][t]
+
+	return n, ok
+}
+
+// DyncastFunctionPtrType returns the function type t points to, or nil if t
+// is not a function pointer type.
+func (t *Type) DyncastFunctionPtrType() *FunctionType {
+	owner := mustBeLiveObject(t)
+	return own(owner, typeDyncastFunctionPtrType(t))
+}
+
+// DyncastStruct returns t as a struct, or nil if t is not a struct type.
+func (t *Type) DyncastStruct() *Struct {
+	owner := mustBeLiveObject(t)
+	return own(owner, typeIsStruct(t))
 }
 
 func (t *Type) IsIntegral() bool {
+	mustBeLiveObject(t)
 	return typeIsIntegral(t)
 }
 
 func (t *Type) IsStruct() bool {
-	return typeIsStruct(t)
+	mustBeLiveObject(t)
+	return typeIsStruct(t) != nil
 }
 
 func (t *Type) Unqualified() *Type {
-	return typeUnqualified(t)
+	owner := mustBeLiveObject(t)
+	return own(owner, typeUnqualified(t))
+}
+
+func (t *Type) GetVector(numUnits int) *Type {
+	owner := mustBeLiveObject(t)
+	return own(owner, typeGetVector(t, numUnits))
+}
+
+// DyncastVector returns t as a vector type, or nil if t is not one.
+func (t *Type) DyncastVector() *Vector {
+	owner := mustBeLiveObject(t)
+	return own(owner, typeDyncastVector(t))
+}
+
+func (t *Type) IsVector() bool {
+	mustBeLiveObject(t)
+	return typeDyncastVector(t) != nil
 }
 
 func (t *Struct) AsType() *Type {
 	return &t.Type
 }
+
+func (t *Struct) GetField(index int) *Field {
+	owner := mustBeLiveObject(t)
+	return own(owner, structGetField(t, index))
+}
+
+func (t *Struct) GetFieldCount() uint64 {
+	mustBeLiveObject(t)
+	return structGetFieldCount(t)
+}
+
+func (t *Struct) SetFields(loc *Location, fields []*Field) {
+	mustBeLiveObject(t)
+	structSetFields(t, loc, len(fields), fields)
+}
+
+func (t *Union) AsType() *Type {
+	return &t.Type
+}
+
+func (v *Vector) GetNumUnits() uint64 {
+	mustBeLiveObject(v)
+	return vectorTypeGetNumUnits(v)
+}
+
+func (v *Vector) GetElementType() *Type {
+	owner := mustBeLiveObject(v)
+	return own(owner, vectorTypeGetElementType(v))
+}
+
+func (v *Vector) AsType() *Type {
+	return &v.Type
+}
+
+func (f *FunctionType) GetReturnType() *Type {
+	owner := mustBeLiveObject(f)
+	return own(owner, functionTypeGetReturnType(f))
+}
+
+func (f *FunctionType) GetParamCount() uint64 {
+	mustBeLiveObject(f)
+	return functionTypeGetParamCount(f)
+}
+
+func (f *FunctionType) GetParamType(index int) *Type {
+	owner := mustBeLiveObject(f)
+	return own(owner, functionTypeGetParamType(f, index))
+}
+
+func (f *FunctionType) AsType() *Type {
+	return &f.Type
+}
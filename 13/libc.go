@@ -0,0 +1,52 @@
+package gccjit
+
+import "unsafe"
+
+// Some libgccjit entry points hand out buffers the caller must free, or take
+// a FILE*, so the C runtime that libgccjit itself links against is bound as
+// well. Memory and streams must not cross C runtimes, so it is looked up
+// through libgccjit rather than by a fixed name.
+var (
+	libcMalloc  func(size uintptr) unsafe.Pointer
+	libcFree    func(ptr unsafe.Pointer)
+	libcStrlen  func(s unsafe.Pointer) uintptr
+	libcFclose  func(stream unsafe.Pointer) int32
+	libcSetvbuf func(stream unsafe.Pointer, buf unsafe.Pointer, mode int32, size uintptr) int32
+)
+
+// loadLibc binds the C runtime of the libgccjit at lib. Like
+// purego.RegisterLibFunc, it panics if it cannot.
+func loadLibc(lib uintptr) {
+	crt, err := crtLibrary(lib)
+	if err != nil {
+		panic(err)
+	}
+
+	registerLibFunc(&libcMalloc, crt, "malloc")
+	registerLibFunc(&libcFree, crt, "free")
+	registerLibFunc(&libcStrlen, crt, "strlen")
+	registerLibFunc(&libcFclose, crt, "fclose")
+	registerLibFunc(&libcSetvbuf, crt, "setvbuf")
+
+	loadLibcStreams(crt)
+}
+
+// cStringMalloc copies s into C memory, for strings libgccjit keeps a
+// pointer to rather than copying. The caller frees it with libcFree.
+func cStringMalloc(s string) unsafe.Pointer {
+	p := libcMalloc(uintptr(len(s) + 1))
+	b := unsafe.Slice((*byte)(p), len(s)+1)
+	copy(b, s)
+	b[len(s)] = 0
+
+	return p
+}
+
+// goString copies the NUL-terminated C string at p into Go memory.
+func goString(p unsafe.Pointer) string {
+	if p == nil {
+		return ""
+	}
+
+	return string(unsafe.Slice((*byte)(p), libcStrlen(p)))
+}
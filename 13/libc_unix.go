@@ -0,0 +1,51 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package gccjit
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const ioNBF = 2
+
+var libcFdopen func(fd int32, mode string) unsafe.Pointer
+
+// crtLibrary returns the handle to look the C runtime up in. dlsym searches
+// a library's dependencies too, so that is libgccjit itself, whichever libc
+// it was linked against.
+func crtLibrary(lib uintptr) (uintptr, error) {
+	return lib, nil
+}
+
+func loadLibcStreams(lib uintptr) {
+	registerLibFunc(&libcFdopen, lib, "fdopen")
+}
+
+// openPipeStream returns the read end of a pipe and a FILE* for its write end.
+func openPipeStream() (*os.File, unsafe.Pointer, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The FILE* takes ownership of its descriptor, so hand it a duplicate.
+	fd, err := syscall.Dup(int(w.Fd()))
+	w.Close()
+	if err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+
+	file := libcFdopen(int32(fd), "w")
+	if file == nil {
+		syscall.Close(fd)
+		r.Close()
+		return nil, nil, errors.New("gccjit: fdopen failed")
+	}
+
+	return r, file, nil
+}
@@ -0,0 +1,89 @@
+//go:build windows
+// +build windows
+
+package gccjit
+
+import (
+	"debug/pe"
+	"errors"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ioNBF   = 4
+	oWRONLY = 1
+)
+
+var (
+	libcOpenOsfhandle func(handle uintptr, flags int32) int32
+	libcFdopen        func(fd int32, mode string) unsafe.Pointer
+	libcClose         func(fd int32) int32
+)
+
+// crtLibrary loads the C runtime that the libgccjit at lib imports: the UCRT
+// for UCRT64 and MSVC builds, whose imports go through the api-ms-win-crt
+// forwarders, or msvcrt.dll for older MinGW builds.
+func crtLibrary(lib uintptr) (uintptr, error) {
+	path := make([]uint16, windows.MAX_LONG_PATH)
+	n, err := windows.GetModuleFileName(windows.Handle(lib), &path[0], uint32(len(path)))
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := pe.Open(windows.UTF16ToString(path[:n]))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	imports, err := f.ImportedLibraries()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, name := range imports {
+		name = strings.ToLower(name)
+		switch {
+		case name == "ucrtbase.dll" || strings.HasPrefix(name, "api-ms-win-crt-"):
+			return loadLibrary("ucrtbase.dll")
+		case name == "msvcrt.dll":
+			return loadLibrary("msvcrt.dll")
+		}
+	}
+
+	return 0, errors.New("gccjit: cannot tell which C runtime libgccjit uses")
+}
+
+func loadLibcStreams(lib uintptr) {
+	registerLibFunc(&libcOpenOsfhandle, lib, "_open_osfhandle")
+	registerLibFunc(&libcFdopen, lib, "_fdopen")
+	registerLibFunc(&libcClose, lib, "_close")
+}
+
+// openPipeStream returns the read end of a pipe and a FILE* for its write end.
+func openPipeStream() (*os.File, unsafe.Pointer, error) {
+	var r, w windows.Handle
+	if err := windows.CreatePipe(&r, &w, nil, 0); err != nil {
+		return nil, nil, err
+	}
+
+	fd := libcOpenOsfhandle(uintptr(w), oWRONLY)
+	if fd < 0 {
+		windows.CloseHandle(w)
+		windows.CloseHandle(r)
+		return nil, nil, errors.New("gccjit: _open_osfhandle failed")
+	}
+
+	file := libcFdopen(fd, "w")
+	if file == nil {
+		libcClose(fd)
+		windows.CloseHandle(r)
+		return nil, nil, errors.New("gccjit: _fdopen failed")
+	}
+
+	return os.NewFile(uintptr(r), "gccjit-pipe"), file, nil
+}
@@ -0,0 +1,475 @@
+package gccjit
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+// Contexts and results are C objects, so Go cannot tell when they are freed.
+// Every one handed out is registered here until it is released, letting
+// later use of a released handle panic with a clear message instead of
+// touching freed memory. The same goes for the types, functions, blocks and
+// other objects a context makes, which it frees along with itself.
+//
+// Nothing is released automatically: a Context or Result dropped without
+// Release is never freed. The handles are pointers into C memory, and
+// runtime.SetFinalizer and runtime.AddCleanup only work on memory the Go
+// runtime allocated, so there is nothing for them to attach to.
+type contextState struct {
+	parent   *Context
+	children int
+
+	// objects are the objects owned by the context that have been handed
+	// out so far, to drop from objectIndex when it is released.
+	objectsMu sync.Mutex
+	objects   []unsafe.Pointer
+}
+
+var (
+	lifetimeMu sync.RWMutex
+	contexts   = map[*Context]*contextState{}
+	results    = map[*Result]*codeRefs{}
+)
+
+// objectIndex maps every object handed out to the context that owns it. It
+// is looked up on every builder call, so it is split into shards that are
+// locked separately, and registering an object only write-locks its shard.
+var objectIndex [64]objectShard
+
+type objectShard struct {
+	mu     sync.RWMutex
+	owners map[unsafe.Pointer]*Context
+}
+
+func objectShardOf(p unsafe.Pointer) *objectShard {
+	// Objects are at least 16-byte aligned, so the low bits carry nothing.
+	return &objectIndex[(uintptr(p)>>4)%uintptr(len(objectIndex))]
+}
+
+func objectOwner(p unsafe.Pointer) *Context {
+	shard := objectShardOf(p)
+
+	shard.mu.RLock()
+	owner := shard.owners[p]
+	shard.mu.RUnlock()
+
+	return owner
+}
+
+func trackContext(c, parent *Context) {
+	lifetimeMu.Lock()
+	defer lifetimeMu.Unlock()
+
+	contexts[c] = &contextState{parent: parent}
+	if parent != nil {
+		contexts[parent].children++
+	}
+}
+
+// parentOf returns the context c was created from with NewChild, if any.
+func parentOf(c *Context) *Context {
+	lifetimeMu.RLock()
+	defer lifetimeMu.RUnlock()
+
+	if s := contexts[c]; s != nil {
+		return s.parent
+	}
+
+	return nil
+}
+
+// mustBeLive panics if c has been released or did not come from this
+// package.
+func (c *Context) mustBeLive() {
+	if c == nil {
+		// ContextAcquire returns nil rather than an error when libgccjit
+		// cannot be loaded, so say why instead of reporting an unknown 0x0.
+		loadMu.Lock()
+		err := loadErr
+		loadMu.Unlock()
+
+		if err != nil {
+			panic(fmt.Sprintf("gccjit: use of nil Context; ContextAcquire returned nil because libgccjit failed to load (use ContextAcquireE to handle this): %v", err))
+		}
+
+		panic("gccjit: use of nil Context")
+	}
+
+	lifetimeMu.RLock()
+	_, ok := contexts[c]
+	lifetimeMu.RUnlock()
+
+	if !ok {
+		panic(fmt.Sprintf("gccjit: use of released or unknown Context %p", c))
+	}
+}
+
+// untrackContext unregisters c before it is released, refusing while child
+// contexts that depend on it are still live.
+func untrackContext(c *Context) {
+	lifetimeMu.Lock()
+	defer lifetimeMu.Unlock()
+
+	s := contexts[c]
+	if s == nil {
+		panic(fmt.Sprintf("gccjit: Release of released or unknown Context %p", c))
+	}
+
+	if s.children > 0 {
+		panic(fmt.Sprintf("gccjit: Release of Context %p with %d live child contexts", c, s.children))
+	}
+
+	if s.parent != nil {
+		contexts[s.parent].children--
+	}
+
+	delete(contexts, c)
+
+	s.objectsMu.Lock()
+	for _, p := range s.objects {
+		shard := objectShardOf(p)
+		shard.mu.Lock()
+		delete(shard.owners, p)
+		shard.mu.Unlock()
+	}
+	s.objects = nil
+	s.objectsMu.Unlock()
+}
+
+// own registers obj, which owner has just handed out, and returns it. The
+// owner is the context a builder was called on, or for methods on objects
+// the owner of the receiver, since libgccjit makes new objects there.
+func own[T any](owner *Context, obj *T) *T {
+	if obj == nil {
+		return nil
+	}
+
+	p := unsafe.Pointer(obj)
+	if objectOwner(p) != nil {
+		return obj
+	}
+
+	lifetimeMu.RLock()
+	s := contexts[owner]
+	lifetimeMu.RUnlock()
+
+	if s == nil {
+		return obj
+	}
+
+	shard := objectShardOf(p)
+	shard.mu.Lock()
+	_, known := shard.owners[p]
+	if !known {
+		if shard.owners == nil {
+			shard.owners = map[unsafe.Pointer]*Context{}
+		}
+
+		shard.owners[p] = owner
+	}
+	shard.mu.Unlock()
+
+	if !known {
+		s.objectsMu.Lock()
+		s.objects = append(s.objects, p)
+		s.objectsMu.Unlock()
+	}
+
+	return obj
+}
+
+// ownShared is own for the builders a child context answers from its
+// parent, such as the builtin types, whose objects must outlive the child.
+// libgccjit is asked for the owner the first time such an object is seen.
+func ownShared[T any](obj *T) *T {
+	if obj == nil || objectOwner(unsafe.Pointer(obj)) != nil {
+		return obj
+	}
+
+	return own(objectGetContext((*Object)(unsafe.Pointer(obj))), obj)
+}
+
+// mustBeLiveObject panics if obj belongs to a released context or did not
+// come from this package, and returns its owner otherwise. A nil obj is let
+// through for libgccjit to report.
+func mustBeLiveObject[T any](obj *T) *Context {
+	if obj == nil {
+		return nil
+	}
+
+	owner := objectOwner(unsafe.Pointer(obj))
+	if owner == nil {
+		panic(fmt.Sprintf("gccjit: use of %T %p from a released or unknown Context", obj, obj))
+	}
+
+	return owner
+}
+
+// codeRefs counts the Go funcs bound to loaded code, a Result or an
+// IsolatedResult, that are still reachable. Released code is only freed once
+// this drops to zero, so a bound func stays callable after Release.
+type codeRefs struct {
+	mu       sync.Mutex
+	holds    int
+	released bool
+	free     func() error
+}
+
+// codeHold keeps code from being freed while a Go func bound to it is
+// reachable.
+type codeHold struct {
+	refs *codeRefs
+}
+
+func newCodeRefs(free func() error) *codeRefs {
+	return &codeRefs{free: free}
+}
+
+func (c *codeRefs) isReleased() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.released
+}
+
+// hold returns a new hold on the code, or false if it has been released.
+func (c *codeRefs) hold() (*codeHold, bool) {
+	c.mu.Lock()
+	if c.released {
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	c.holds++
+	c.mu.Unlock()
+
+	h := &codeHold{refs: c}
+	runtime.SetFinalizer(h, (*codeHold).drop)
+
+	return h, true
+}
+
+// release marks the code released and frees it unless it is still held, in
+// which case the last hold to be dropped frees it and any error is lost. It
+// returns false if the code was already released.
+func (c *codeRefs) release() (bool, error) {
+	c.mu.Lock()
+	if c.released {
+		c.mu.Unlock()
+		return false, nil
+	}
+
+	c.released = true
+	free := c.holds == 0
+	c.mu.Unlock()
+
+	if free {
+		return true, c.free()
+	}
+
+	return true, nil
+}
+
+func (h *codeHold) drop() {
+	c := h.refs
+
+	c.mu.Lock()
+	c.holds--
+	free := c.released && c.holds == 0
+	c.mu.Unlock()
+
+	if free {
+		c.free()
+	}
+}
+
+func trackResult(r *Result) {
+	refs := newCodeRefs(func() error {
+		freeResult(r)
+		return nil
+	})
+
+	lifetimeMu.Lock()
+	results[r] = refs
+	lifetimeMu.Unlock()
+}
+
+func resultRefs(r *Result) *codeRefs {
+	lifetimeMu.RLock()
+	defer lifetimeMu.RUnlock()
+
+	return results[r]
+}
+
+// mustBeLive panics if r has been released or did not come from this
+// package.
+func (r *Result) mustBeLive() {
+	if refs := resultRefs(r); refs == nil || refs.isReleased() {
+		panic(fmt.Sprintf("gccjit: use of released or unknown Result %p", r))
+	}
+}
+
+// releaseResult marks r released and frees it, unless funcs bound to it are
+// still reachable, in which case the last of them to be collected frees it.
+func releaseResult(r *Result) {
+	refs := resultRefs(r)
+	if refs == nil {
+		panic(fmt.Sprintf("gccjit: Release of released or unknown Result %p", r))
+	}
+
+	if ok, _ := refs.release(); !ok {
+		panic(fmt.Sprintf("gccjit: Release of released or unknown Result %p", r))
+	}
+}
+
+func freeResult(r *Result) {
+	lifetimeMu.Lock()
+	delete(results, r)
+	lifetimeMu.Unlock()
+
+	resultRelease(r)
+	forgetResultGlobals(r)
+	releaseResultLogger(r)
+}
+
+// holdResult keeps r from being freed while the returned hold is reachable.
+// r must not have been released.
+func holdResult(r *Result) *codeHold {
+	h, ok := resultRefs(r).hold()
+	if !ok {
+		panic(fmt.Sprintf("gccjit: use of released Result %p", r))
+	}
+
+	return h
+}
+
+// bindFunc points the func that fptr points to at the code at addr in r. The
+// func keeps r from being freed for as long as it is reachable, so releasing
+// r while it is still in use is safe.
+func bindFunc(r *Result, fptr any, addr uintptr) {
+	bindCode(fptr, addr, holdResult(r))
+}
+
+// bindCode points the func that fptr points to at the code at addr, keeping
+// hold reachable for as long as the func is. Signatures made only of
+// integers, bools, pointers and strings are called through purego.SyscallN
+// from a single reflect layer, so the hold adds nothing per call; others are
+// bound with purego.RegisterFunc and wrapped.
+func bindCode(fptr any, addr uintptr, hold any) {
+	fn := reflect.ValueOf(fptr).Elem()
+	ty := fn.Type()
+
+	if !syscallSignature(ty) {
+		inner := reflect.New(ty)
+		purego.RegisterFunc(inner.Interface(), addr)
+		call := inner.Elem()
+
+		fn.Set(reflect.MakeFunc(ty, func(args []reflect.Value) []reflect.Value {
+			defer runtime.KeepAlive(hold)
+
+			if ty.IsVariadic() {
+				return call.CallSlice(args)
+			}
+
+			return call.Call(args)
+		}))
+
+		return
+	}
+
+	fn.Set(reflect.MakeFunc(ty, func(args []reflect.Value) []reflect.Value {
+		defer runtime.KeepAlive(hold)
+
+		var sysargs [syscallMaxArgs]uintptr
+		var strs []*byte
+		for i, v := range args {
+			switch v.Kind() {
+			case reflect.Bool:
+				if v.Bool() {
+					sysargs[i] = 1
+				}
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				sysargs[i] = uintptr(v.Int())
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				sysargs[i] = uintptr(v.Uint())
+			case reflect.Pointer, reflect.UnsafePointer, reflect.Slice:
+				sysargs[i] = v.Pointer()
+			case reflect.String:
+				str := cString(v.String())
+				strs = append(strs, str)
+				sysargs[i] = uintptr(unsafe.Pointer(str))
+			}
+		}
+
+		r1, _, _ := purego.SyscallN(addr, sysargs[:len(args)]...)
+		runtime.KeepAlive(args)
+		runtime.KeepAlive(strs)
+
+		if ty.NumOut() == 0 {
+			return nil
+		}
+
+		out := reflect.New(ty.Out(0)).Elem()
+		switch out.Kind() {
+		case reflect.Bool:
+			out.SetBool(byte(r1) != 0)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			out.SetInt(int64(r1))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			out.SetUint(uint64(r1))
+		case reflect.Pointer:
+			out = reflect.NewAt(out.Type().Elem(), cPointer(r1))
+		case reflect.UnsafePointer:
+			out.SetPointer(cPointer(r1))
+		case reflect.String:
+			out.SetString(goString(cPointer(r1)))
+		case reflect.Func:
+			// A function pointer returned by the code may well point into
+			// it, so the func bound to it holds the code too.
+			if r1 != 0 {
+				bindCode(out.Addr().Interface(), r1, hold)
+			}
+		}
+
+		return []reflect.Value{out}
+	}))
+}
+
+// syscallMaxArgs is the number of arguments purego.SyscallN takes.
+const syscallMaxArgs = 9
+
+// syscallSignature reports whether calls of type ty can be made with
+// purego.SyscallN: no more than syscallMaxArgs arguments, none of them
+// floating-point or funcs, and a result that is not floating-point.
+func syscallSignature(ty reflect.Type) bool {
+	if ty.IsVariadic() || ty.NumIn() > syscallMaxArgs || ty.NumOut() > 1 {
+		return false
+	}
+
+	for i := 0; i < ty.NumIn(); i++ {
+		switch ty.In(i).Kind() {
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Pointer, reflect.UnsafePointer, reflect.Slice, reflect.String:
+		default:
+			return false
+		}
+	}
+
+	if ty.NumOut() == 1 {
+		switch ty.Out(0).Kind() {
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Pointer, reflect.UnsafePointer, reflect.String, reflect.Func:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
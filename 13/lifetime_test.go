@@ -0,0 +1,125 @@
+package gccjit
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+func TestSyscallSignature(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   any
+		want bool
+	}{
+		{"empty", func() {}, true},
+		{"integers", func(int8, uint16, int32, uint64, uintptr, bool) int64 { return 0 }, true},
+		{"pointers", func(*byte, unsafe.Pointer, []int32, string) *int32 { return nil }, true},
+		{"string result", func() string { return "" }, true},
+		{"func result", func() func(int32) int32 { return nil }, true},
+		{"nine arguments", func(int, int, int, int, int, int, int, int, int) {}, true},
+		{"ten arguments", func(int, int, int, int, int, int, int, int, int, int) {}, false},
+		{"float argument", func(float64) {}, false},
+		{"float result", func() float32 { return 0 }, false},
+		{"func argument", func(func()) {}, false},
+		{"variadic", func(...int) {}, false},
+		{"struct argument", func(struct{ a int }) {}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := syscallSignature(reflect.TypeOf(tt.fn)); got != tt.want {
+				t.Errorf("syscallSignature(%T) = %t, want %t", tt.fn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindCode(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("binds functions from libc.so.6")
+	}
+
+	libc, err := loadLibrary("libc.so.6")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	sym := func(name string) uintptr {
+		addr, err := loadSymbol(libc, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return addr
+	}
+
+	// goString needs strlen.
+	loadLibc(libc)
+
+	var strlen func(string) uintptr
+	bindCode(&strlen, sym("strlen"), nil)
+	if n := strlen("gccjit"); n != 6 {
+		t.Errorf("strlen = %d, want 6", n)
+	}
+
+	var abs func(int32) int32
+	bindCode(&abs, sym("abs"), nil)
+	if n := abs(-42); n != 42 {
+		t.Errorf("abs = %d, want 42", n)
+	}
+
+	var strchr func(string, int32) string
+	bindCode(&strchr, sym("strchr"), nil)
+	if s := strchr("lib:gccjit", ':'); s != ":gccjit" {
+		t.Errorf("strchr = %q, want %q", s, ":gccjit")
+	}
+
+	var getenv func(string) *byte
+	bindCode(&getenv, sym("getenv"), nil)
+	if p := getenv("GOGCCJIT_SURELY_UNSET"); p != nil {
+		t.Errorf("getenv = %p, want nil", p)
+	}
+}
+
+func TestCodeRefs(t *testing.T) {
+	freed := 0
+	refs := newCodeRefs(func() error {
+		freed++
+		return nil
+	})
+
+	h1, ok := refs.hold()
+	if !ok {
+		t.Fatal("hold before release failed")
+	}
+
+	h2, _ := refs.hold()
+
+	// Drop the holds by hand rather than waiting for the collector.
+	runtime.SetFinalizer(h1, nil)
+	runtime.SetFinalizer(h2, nil)
+
+	if ok, _ := refs.release(); !ok || freed != 0 {
+		t.Fatalf("release = %t with %d frees, want true with none while held", ok, freed)
+	}
+
+	if _, ok := refs.hold(); ok {
+		t.Error("hold after release succeeded")
+	}
+
+	if ok, _ := refs.release(); ok {
+		t.Error("second release succeeded")
+	}
+
+	h1.drop()
+	if freed != 0 {
+		t.Fatalf("freed with a hold left")
+	}
+
+	h2.drop()
+	if freed != 1 {
+		t.Fatalf("freed %d times after the last hold was dropped, want 1", freed)
+	}
+}
@@ -0,0 +1,192 @@
+package gccjit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LibraryEnv names the environment variable that, when set, gives the path
+// of the libgccjit to load ahead of the default names.
+const LibraryEnv = "GOGCCJIT_LIBRARY"
+
+// LoadOption configures Load.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	paths []string
+}
+
+// WithLibrary makes Load try path before GOGCCJIT_LIBRARY and the default
+// names. It may be given several times; paths are tried in order.
+func WithLibrary(path string) LoadOption {
+	return func(o *loadOptions) {
+		o.paths = append(o.paths, path)
+	}
+}
+
+var (
+	loadMu sync.Mutex
+	loaded atomic.Bool
+	// loadErr is why the last Load failed. It is returned by later calls
+	// without options rather than searching for the library again.
+	loadErr error
+)
+
+// Load loads libgccjit and binds its entry points. It is called on first use
+// of ContextAcquire and the other package-level functions, so calling it
+// directly is only needed to choose the library or to handle a missing one.
+//
+// Candidates are tried in order: the WithLibrary paths, the GOGCCJIT_LIBRARY
+// environment variable, then the usual names for the platform, both through
+// the system loader and in each LD_LIBRARY_PATH (DYLD_LIBRARY_PATH on
+// macOS) directory. Once a library is loaded, later calls return nil and
+// ignore their options. A failed Load is remembered: later calls without
+// options, including those made on first use, return the same error, while
+// calls with options search again.
+func Load(opts ...LoadOption) error {
+	if loaded.Load() {
+		return nil
+	}
+
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	if loaded.Load() {
+		return nil
+	}
+
+	if loadErr != nil && len(opts) == 0 {
+		return loadErr
+	}
+
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	candidates := libraryCandidates(o)
+	if len(candidates) == 0 {
+		return fmt.Errorf("gccjit: GOOS=%s is not supported", runtime.GOOS)
+	}
+
+	var errs []error
+	for _, path := range candidates {
+		lib, err := loadLibrary(path)
+		if err != nil {
+			// dlerror already names the path, LoadLibrary does not.
+			if !strings.Contains(err.Error(), path) {
+				err = fmt.Errorf("%s: %w", path, err)
+			}
+
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := bindLibrary(lib); err != nil {
+			closeLibrary(lib)
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+
+		loaded.Store(true)
+		loadErr = nil
+
+		return nil
+	}
+
+	loadErr = fmt.Errorf("gccjit: cannot load libgccjit: %w", errors.Join(errs...))
+
+	return loadErr
+}
+
+// Available reports whether libgccjit is loaded, loading it with the default
+// options if that has not been tried yet. After a failed load it returns
+// false without trying again.
+func Available() bool {
+	return Load() == nil
+}
+
+// mustLoad loads libgccjit for entry points that have no way to report
+// failure, and panics with the reason if it cannot be loaded.
+func mustLoad() {
+	if err := Load(); err != nil {
+		panic(err)
+	}
+}
+
+// bindLibrary binds every entry point from lib, and leaves none bound if a
+// required one is missing.
+func bindLibrary(lib uintptr) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			unbindLibFuncs()
+
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	boundFuncs = nil
+	registerLibFuncs(lib)
+
+	return nil
+}
+
+func libraryCandidates(o loadOptions) []string {
+	candidates := append([]string(nil), o.paths...)
+	if path := os.Getenv(LibraryEnv); path != "" {
+		candidates = append(candidates, path)
+	}
+
+	names := libraryNames()
+	candidates = append(candidates, names...)
+
+	var searchPath string
+	switch runtime.GOOS {
+	case "linux":
+		searchPath = os.Getenv("LD_LIBRARY_PATH")
+	case "darwin":
+		searchPath = os.Getenv("DYLD_LIBRARY_PATH")
+	}
+
+	for _, dir := range filepath.SplitList(searchPath) {
+		if dir == "" {
+			continue
+		}
+
+		for _, name := range names {
+			if filepath.Base(name) == name {
+				candidates = append(candidates, filepath.Join(dir, name))
+			}
+		}
+	}
+
+	return candidates
+}
+
+func libraryNames() []string {
+	switch runtime.GOOS {
+	case "linux":
+		return []string{"libgccjit.so.0", "libgccjit.so"}
+	case "darwin":
+		return []string{
+			"libgccjit.0.dylib",
+			"libgccjit.dylib",
+			"/opt/homebrew/lib/gcc/current/libgccjit.0.dylib",
+			"/usr/local/lib/gcc/current/libgccjit.0.dylib",
+		}
+	case "windows":
+		return []string{"libgccjit-0.dll", "libgccjit.dll"}
+	default:
+		return nil
+	}
+}
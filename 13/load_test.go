@@ -0,0 +1,83 @@
+package gccjit
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestLibraryCandidates(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("expected candidates are written for linux")
+	}
+
+	tests := []struct {
+		name   string
+		paths  []string
+		env    string
+		ldPath string
+		want   []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"libgccjit.so.0", "libgccjit.so"},
+		},
+		{
+			name:  "WithLibrary first",
+			paths: []string{"/opt/gcc/lib/libgccjit.so.0", "./libgccjit.so"},
+			want:  []string{"/opt/gcc/lib/libgccjit.so.0", "./libgccjit.so", "libgccjit.so.0", "libgccjit.so"},
+		},
+		{
+			name:  "environment after WithLibrary",
+			paths: []string{"/a/libgccjit.so"},
+			env:   "/b/libgccjit.so.0",
+			want:  []string{"/a/libgccjit.so", "/b/libgccjit.so.0", "libgccjit.so.0", "libgccjit.so"},
+		},
+		{
+			name:   "search path last",
+			env:    "/b/libgccjit.so.0",
+			ldPath: "/x/lib::/y/lib",
+			want: []string{
+				"/b/libgccjit.so.0",
+				"libgccjit.so.0", "libgccjit.so",
+				"/x/lib/libgccjit.so.0", "/x/lib/libgccjit.so",
+				"/y/lib/libgccjit.so.0", "/y/lib/libgccjit.so",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(LibraryEnv, tt.env)
+			t.Setenv("LD_LIBRARY_PATH", tt.ldPath)
+
+			got := libraryCandidates(loadOptions{paths: tt.paths})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("libraryCandidates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindLibraryUnbinds(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("binds libc.so.6 as a library without libgccjit's symbols")
+	}
+
+	if loaded.Load() {
+		t.Skip("libgccjit is already bound")
+	}
+
+	lib, err := loadLibrary("libc.so.6")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	if err := bindLibrary(lib); err == nil {
+		t.Fatal("bindLibrary(libc.so.6) succeeded")
+	}
+
+	if contextAcquire != nil || libcMalloc != nil || len(boundFuncs) != 0 {
+		t.Error("bindLibrary left functions bound after it failed")
+	}
+}
@@ -8,3 +8,11 @@ import "github.com/ebitengine/purego"
 func loadLibrary(path string) (uintptr, error) {
 	return purego.Dlopen(path, purego.RTLD_NOW|purego.RTLD_GLOBAL)
 }
+
+func loadSymbol(lib uintptr, name string) (uintptr, error) {
+	return purego.Dlsym(lib, name)
+}
+
+func closeLibrary(lib uintptr) error {
+	return purego.Dlclose(lib)
+}
@@ -10,3 +10,11 @@ func loadLibrary(path string) (uintptr, error) {
 
 	return uintptr(ptr), err
 }
+
+func loadSymbol(lib uintptr, name string) (uintptr, error) {
+	return windows.GetProcAddress(windows.Handle(lib), name)
+}
+
+func closeLibrary(lib uintptr) error {
+	return windows.FreeLibrary(windows.Handle(lib))
+}
@@ -0,0 +1,115 @@
+package gccjit
+
+import (
+	"io"
+	"sync"
+)
+
+// libgccjit keeps its own references to a context's logger: child contexts
+// take their parent's logger when they are created, and results keep the
+// logger of the context they were compiled from and write to it until they
+// are released. The stream behind a logger is therefore reference counted
+// and only closed once none of them can write to it any more.
+type logger struct {
+	stream *streamWriter
+	refs   int
+}
+
+var (
+	loggersMu     sync.Mutex
+	loggers       = map[*Context]*logger{}
+	resultLoggers = map[*Result]*logger{}
+)
+
+// SetLogger sends libgccjit's internal log for c to w. A nil w turns logging
+// off. The log is flushed to w once logging is turned off or replaced and c,
+// the child contexts created while it was set and the results compiled with
+// it have all been released. libgccjit has a single log level; its flags and
+// verbosity arguments must be zero, so they are not exposed.
+func (c *Context) SetLogger(w io.Writer) error {
+	c.mustBeLive()
+
+	var l *logger
+	if w != nil {
+		s, err := newStreamWriter(w)
+		if err != nil {
+			return err
+		}
+
+		l = &logger{stream: s, refs: 1}
+		contextSetLogfile(c, s.file, 0, 0)
+	} else {
+		contextSetLogfile(c, nil, 0, 0)
+	}
+
+	loggersMu.Lock()
+	old := loggers[c]
+	if l != nil {
+		loggers[c] = l
+	} else {
+		delete(loggers, c)
+	}
+	loggersMu.Unlock()
+
+	return old.unref()
+}
+
+// inheritLogger gives child a reference to the logger of its parent, which
+// libgccjit hands to the child when it is created.
+func inheritLogger(child, parent *Context) {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+
+	if l := loggers[parent]; l != nil {
+		l.refs++
+		loggers[child] = l
+	}
+}
+
+// holdLogger gives r a reference to the logger of the context it was
+// compiled from.
+func holdLogger(c *Context, r *Result) {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+
+	if l := loggers[c]; l != nil {
+		l.refs++
+		resultLoggers[r] = l
+	}
+}
+
+func releaseLogger(c *Context) error {
+	loggersMu.Lock()
+	l := loggers[c]
+	delete(loggers, c)
+	loggersMu.Unlock()
+
+	return l.unref()
+}
+
+func releaseResultLogger(r *Result) error {
+	loggersMu.Lock()
+	l := resultLoggers[r]
+	delete(resultLoggers, r)
+	loggersMu.Unlock()
+
+	return l.unref()
+}
+
+// unref drops a reference to l, closing its stream when it was the last.
+func (l *logger) unref() error {
+	if l == nil {
+		return nil
+	}
+
+	loggersMu.Lock()
+	l.refs--
+	last := l.refs == 0
+	loggersMu.Unlock()
+
+	if last {
+		return l.stream.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package gccjit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Pool.Submit after Pool.Close.
+var ErrPoolClosed = errors.New("gccjit: pool is closed")
+
+// Pool compiles on a fixed set of worker goroutines, each locked to its own
+// OS thread. A libgccjit context must only be used from one thread at a
+// time, so rather than sharing a *Context between goroutines, submit a build
+// function and let the pool give it a fresh context on a worker thread.
+type Pool struct {
+	jobs    chan *poolJob
+	wg      sync.WaitGroup
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+type poolJob struct {
+	ctx    context.Context
+	build  func(*Context) error
+	future *Future
+}
+
+// Future is the pending outcome of a build submitted to a Pool.
+type Future struct {
+	done   chan struct{}
+	result *Result
+	err    error
+}
+
+// NewPool starts a pool of workers goroutines. Up to queueDepth builds may
+// wait for a free worker before Submit blocks.
+func NewPool(workers, queueDepth int) (*Pool, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("gccjit: pool needs at least one worker, got %d", workers)
+	}
+
+	if queueDepth < 0 {
+		return nil, fmt.Errorf("gccjit: negative pool queue depth %d", queueDepth)
+	}
+
+	if err := Load(); err != nil {
+		return nil, err
+	}
+
+	p := &Pool{jobs: make(chan *poolJob, queueDepth)}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p, nil
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for job := range p.jobs {
+		job.future.result, job.future.err = runJob(job)
+		close(job.future.done)
+	}
+}
+
+func runJob(job *poolJob) (r *Result, err error) {
+	// A panicking build must not take the worker down with it, or the job's
+	// Future would never complete.
+	defer func() {
+		if v := recover(); v != nil {
+			r, err = nil, fmt.Errorf("gccjit: build panicked: %v", v)
+		}
+	}()
+
+	if err := job.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c, err := ContextAcquireE()
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.Release()
+
+	if err := job.build(c); err != nil {
+		return nil, err
+	}
+
+	// A compile already under way cannot be interrupted, so cancellation
+	// during it is only noticed afterwards.
+	if err := job.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r, err = c.CompileE()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := job.ctx.Err(); err != nil {
+		r.Release()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Submit queues build to run on a worker with a new context, which is then
+// compiled and released. It blocks while the queue is full, until ctx is
+// done. ctx also cancels the build if it is done before a worker gets to it
+// or before compilation finishes. If build panics, the panic is recovered
+// and reported as the Future's error.
+//
+// The caller owns the Result of a successful build and must release it.
+func (p *Pool) Submit(ctx context.Context, build func(*Context) error) (*Future, error) {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	job := &poolJob{ctx: ctx, build: build, future: &Future{done: make(chan struct{})}}
+
+	select {
+	case p.jobs <- job:
+		return job.future, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops accepting builds, waits for queued ones to finish and stops
+// the workers.
+func (p *Pool) Close() {
+	p.closeMu.Lock()
+	if p.closed {
+		p.closeMu.Unlock()
+		return
+	}
+
+	p.closed = true
+	close(p.jobs)
+	p.closeMu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Done is closed when the build has finished.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the build has finished or ctx is done. Giving up on a
+// build through ctx does not cancel it; use the ctx passed to Submit for
+// that.
+func (f *Future) Wait(ctx context.Context) (*Result, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
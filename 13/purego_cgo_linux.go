@@ -0,0 +1,9 @@
+//go:build cgo
+// +build cgo
+
+package gccjit
+
+// With cgo on Linux, purego calls C through cgo, which leaves the
+// floating-point registers out: float arguments abort the process and float
+// results read as zero.
+const puregoFloats = false
@@ -0,0 +1,6 @@
+//go:build !cgo || !linux
+// +build !cgo !linux
+
+package gccjit
+
+const puregoFloats = true
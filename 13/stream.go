@@ -0,0 +1,43 @@
+package gccjit
+
+import (
+	"errors"
+	"io"
+	"unsafe"
+)
+
+// streamWriter is a C FILE* whose output is copied into an io.Writer, for
+// libgccjit entry points that write to a FILE*.
+type streamWriter struct {
+	file unsafe.Pointer
+	done chan error
+}
+
+func newStreamWriter(w io.Writer) (*streamWriter, error) {
+	r, file, err := openPipeStream()
+	if err != nil {
+		return nil, err
+	}
+
+	// Unbuffered, so output reaches w as libgccjit writes it rather than
+	// when the stream is closed.
+	libcSetvbuf(file, nil, ioNBF, 0)
+
+	s := &streamWriter{file: file, done: make(chan error, 1)}
+	go func() {
+		_, err := io.Copy(w, r)
+		s.done <- errors.Join(err, r.Close())
+	}()
+
+	return s, nil
+}
+
+// Close closes the stream and waits until everything written to it has been
+// copied.
+func (s *streamWriter) Close() error {
+	if libcFclose(s.file) != 0 {
+		return errors.New("gccjit: fclose failed")
+	}
+
+	return <-s.done
+}
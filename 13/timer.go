@@ -0,0 +1,251 @@
+package gccjit
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimerItem is one row of a Timer report.
+type TimerItem struct {
+	Name string
+	User time.Duration
+	Sys  time.Duration
+	Wall time.Duration
+	// GGCMemory is the memory allocated by GCC's garbage collector, in bytes.
+	GGCMemory uint64
+	// Children are the client items pushed while this one was active.
+	Children []*TimerItem
+}
+
+// TimerReport is the parsed form of the report printed by Timer.Print.
+type TimerReport struct {
+	// Phases are GCC's own phases and time variables.
+	Phases []*TimerItem
+	// Items are the outermost items pushed with Timer.Push, with the items
+	// pushed inside them as their Children.
+	Items []*TimerItem
+	Total *TimerItem
+}
+
+// libgccjit reports client items as a flat list, so the nesting of Push
+// calls is recorded on the Go side.
+type timerItems struct {
+	stack  []string
+	parent map[string]string
+}
+
+var (
+	timerItemsMu sync.Mutex
+	timerTrees   = map[*Timer]*timerItems{}
+)
+
+func pushTimerItem(t *Timer, name string) {
+	timerItemsMu.Lock()
+	defer timerItemsMu.Unlock()
+
+	items := timerTrees[t]
+	if items == nil {
+		items = &timerItems{parent: map[string]string{}}
+		timerTrees[t] = items
+	}
+
+	if _, ok := items.parent[name]; !ok && len(items.stack) > 0 {
+		items.parent[name] = items.stack[len(items.stack)-1]
+	}
+
+	items.stack = append(items.stack, name)
+}
+
+func popTimerItem(t *Timer) {
+	timerItemsMu.Lock()
+	defer timerItemsMu.Unlock()
+
+	if items := timerTrees[t]; items != nil && len(items.stack) > 0 {
+		items.stack = items.stack[:len(items.stack)-1]
+	}
+}
+
+func forgetTimerItems(t *Timer) {
+	timerItemsMu.Lock()
+	delete(timerTrees, t)
+	timerItemsMu.Unlock()
+}
+
+// Print returns the report libgccjit prints for t: the time spent in each
+// phase, including the items pushed from Go.
+func (t *Timer) Print() (string, error) {
+	var buf bytes.Buffer
+
+	s, err := newStreamWriter(&buf)
+	if err != nil {
+		return "", err
+	}
+
+	timerPrint(t, s.file)
+	if err := s.Close(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Report returns the report for t as Go values.
+func (t *Timer) Report() (*TimerReport, error) {
+	text, err := t.Print()
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := parseTimerReport(text)
+	if err != nil {
+		return nil, err
+	}
+
+	timerItemsMu.Lock()
+	var parent map[string]string
+	if items := timerTrees[t]; items != nil {
+		parent = items.parent
+	}
+	report.Items = nestTimerItems(report.Items, parent)
+	timerItemsMu.Unlock()
+
+	return report, nil
+}
+
+var (
+	timerPercent = regexp.MustCompile(`\(\s*[\d.]+%\)`)
+	timerMemory  = regexp.MustCompile(`^(\d+)([kMG]?)$`)
+)
+
+// parseTimerReport parses the output of gcc_jit_timer_print. It accepts the
+// row layout of GCC 10 and newer,
+//
+//	phase setup        :   0.00 (  0%)   0.00 (  0%)   0.01 (  5%)  1436k ( 50%)
+//
+// as well as the older one with "usr", "sys", "wall" and "kB ... ggc" labels.
+func parseTimerReport(text string) (*TimerReport, error) {
+	report := &TimerReport{}
+	client := false
+
+	for _, line := range strings.Split(text, "\n") {
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(line[:colon])
+		values := strings.TrimSpace(line[colon+1:])
+		if values == "" {
+			client = name == "Client items"
+			continue
+		}
+
+		item, err := parseTimerRow(name, values)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case name == "TOTAL":
+			report.Total = item
+		case client:
+			report.Items = append(report.Items, item)
+		default:
+			report.Phases = append(report.Phases, item)
+		}
+	}
+
+	if report.Total == nil {
+		return nil, fmt.Errorf("gccjit: timer report has no TOTAL row")
+	}
+
+	return report, nil
+}
+
+func parseTimerRow(name, values string) (*TimerItem, error) {
+	kB := strings.Contains(values, "kB")
+
+	values = timerPercent.ReplaceAllString(values, "")
+	fields := strings.FieldsFunc(values, func(r rune) bool { return r == ' ' || r == '\t' })
+
+	var numbers []string
+	for _, f := range fields {
+		switch f {
+		case "usr", "sys", "wall", "ggc", "kB":
+		default:
+			numbers = append(numbers, f)
+		}
+	}
+
+	// The memory column may be printed as "1436 k" rather than "1436k".
+	if len(numbers) == 5 && len(numbers[4]) == 1 {
+		numbers = append(numbers[:3], numbers[3]+numbers[4])
+	}
+
+	if len(numbers) != 4 {
+		return nil, fmt.Errorf("gccjit: malformed timer report row %q", name)
+	}
+
+	item := &TimerItem{Name: name}
+	for i, d := range []*time.Duration{&item.User, &item.Sys, &item.Wall} {
+		seconds, err := strconv.ParseFloat(numbers[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gccjit: malformed timer report row %q: %w", name, err)
+		}
+
+		*d = time.Duration(seconds * float64(time.Second))
+	}
+
+	m := timerMemory.FindStringSubmatch(numbers[3])
+	if m == nil {
+		return nil, fmt.Errorf("gccjit: malformed timer report row %q", name)
+	}
+
+	mem, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("gccjit: malformed timer report row %q: %w", name, err)
+	}
+
+	unit := m[2]
+	if unit == "" && kB {
+		unit = "k"
+	}
+
+	switch unit {
+	case "k":
+		mem <<= 10
+	case "M":
+		mem <<= 20
+	case "G":
+		mem <<= 30
+	}
+
+	item.GGCMemory = mem
+
+	return item, nil
+}
+
+// nestTimerItems moves each item under the item that was active when it was
+// first pushed, and returns the outermost ones.
+func nestTimerItems(items []*TimerItem, parent map[string]string) []*TimerItem {
+	byName := make(map[string]*TimerItem, len(items))
+	for _, item := range items {
+		byName[item.Name] = item
+	}
+
+	var roots []*TimerItem
+	for _, item := range items {
+		if p, ok := byName[parent[item.Name]]; ok && p != item {
+			p.Children = append(p.Children, item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	return roots
+}
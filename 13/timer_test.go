@@ -0,0 +1,223 @@
+package gccjit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timerReportGCC13 is laid out the way gcc_jit_timer_print prints a report in
+// GCC 10 and newer, here for a compile inside the client items "compile" and
+// "optimize".
+const timerReportGCC13 = `
+Time variable                                   usr           sys          wall           GGC
+ phase setup                        :   0.00 (  0%)   0.00 (  0%)   0.01 (  5%)  1436k ( 50%)
+ phase opt and generate             :   0.01 (100%)   0.00 (  0%)   0.02 ( 10%)  1384k ( 48%)
+ callgraph construction             :   0.00 (  0%)   0.00 (  0%)   0.01 (  5%)   776  (  0%)
+ initialize rtl                     :   0.00 (  0%)   0.00 (  0%)   0.01 (  5%)    12M (  1%)
+Client items:
+ compile                            :   0.00 (  0%)   0.00 (  0%)   0.15 ( 75%)     0  (  0%)
+ optimize                           :   0.00 (  0%)   0.00 (  0%)   0.01 (  5%)     0  (  0%)
+ TOTAL                              :   0.01          0.00          0.20         2860k
+`
+
+// timerReportGCC9 has the row layout used before GCC 10.
+const timerReportGCC9 = `
+Execution times (seconds)
+ phase setup             :   0.00 ( 0%) usr   0.00 ( 0%) sys   0.01 ( 8%) wall    1094 kB (51%) ggc
+ phase opt and generate  :   0.01 (100%) usr   0.00 ( 0%) sys   0.02 (17%) wall     980 kB (46%) ggc
+Client items:
+ compile                 :   0.00 ( 0%) usr   0.00 ( 0%) sys   0.09 (75%) wall       0 kB ( 0%) ggc
+ TOTAL                 :   0.01             0.00             0.12               2135 kB
+`
+
+func timerItem(name string, user, wall time.Duration, mem uint64) *TimerItem {
+	return &TimerItem{Name: name, User: user, Wall: wall, GGCMemory: mem}
+}
+
+func TestParseTimerReport(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name string
+		text string
+		want *TimerReport
+	}{
+		{
+			name: "gcc 13",
+			text: timerReportGCC13,
+			want: &TimerReport{
+				Phases: []*TimerItem{
+					timerItem("phase setup", 0, 10*ms, 1436<<10),
+					timerItem("phase opt and generate", 10*ms, 20*ms, 1384<<10),
+					timerItem("callgraph construction", 0, 10*ms, 776),
+					timerItem("initialize rtl", 0, 10*ms, 12<<20),
+				},
+				Items: []*TimerItem{
+					timerItem("compile", 0, 150*ms, 0),
+					timerItem("optimize", 0, 10*ms, 0),
+				},
+				Total: timerItem("TOTAL", 10*ms, 200*ms, 2860<<10),
+			},
+		},
+		{
+			name: "gcc 9",
+			text: timerReportGCC9,
+			want: &TimerReport{
+				Phases: []*TimerItem{
+					timerItem("phase setup", 0, 10*ms, 1094<<10),
+					timerItem("phase opt and generate", 10*ms, 20*ms, 980<<10),
+				},
+				Items: []*TimerItem{
+					timerItem("compile", 0, 90*ms, 0),
+				},
+				Total: timerItem("TOTAL", 10*ms, 120*ms, 2135<<10),
+			},
+		},
+		{
+			name: "split memory unit",
+			text: " phase setup :   0.00 (  0%)   0.00 (  0%)   0.01 (  5%)  1436 k ( 50%)\n TOTAL :   0.00   0.00   0.01   1436 k\n",
+			want: &TimerReport{
+				Phases: []*TimerItem{timerItem("phase setup", 0, 10*ms, 1436<<10)},
+				Total:  timerItem("TOTAL", 0, 10*ms, 1436<<10),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimerReport(tt.text)
+			if err != nil {
+				t.Fatalf("parseTimerReport: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTimerReport:\ngot  %s\nwant %s", formatTimerReport(got), formatTimerReport(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseTimerReportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "no total",
+			text: " phase setup :   0.00 (  0%)   0.00 (  0%)   0.01 (  5%)  1436k ( 50%)\n",
+			want: "no TOTAL row",
+		},
+		{
+			name: "missing column",
+			text: " phase setup :   0.00 (  0%)   0.01 (  5%)  1436k ( 50%)\n TOTAL :   0.00   0.00   0.01   1436k\n",
+			want: `malformed timer report row "phase setup"`,
+		},
+		{
+			name: "bad time",
+			text: " TOTAL :   0.00   x.yz   0.01   1436k\n",
+			want: `malformed timer report row "TOTAL"`,
+		},
+		{
+			name: "bad memory unit",
+			text: " TOTAL :   0.00   0.00   0.01   1436q\n",
+			want: `malformed timer report row "TOTAL"`,
+		},
+		{
+			name: "empty",
+			text: "",
+			want: "no TOTAL row",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTimerReport(tt.text)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseTimerReport error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNestTimerItems(t *testing.T) {
+	tests := []struct {
+		name   string
+		items  []string
+		parent map[string]string
+		want   string
+	}{
+		{
+			name:  "flat",
+			items: []string{"a", "b"},
+			want:  "a b",
+		},
+		{
+			name:   "nested",
+			items:  []string{"compile", "parse", "optimize", "inline"},
+			parent: map[string]string{"parse": "compile", "optimize": "compile", "inline": "optimize"},
+			want:   "compile(parse optimize(inline))",
+		},
+		{
+			name:   "parent not in report",
+			items:  []string{"parse"},
+			parent: map[string]string{"parse": "compile"},
+			want:   "parse",
+		},
+		{
+			name:   "own parent",
+			items:  []string{"loop"},
+			parent: map[string]string{"loop": "loop"},
+			want:   "loop",
+		},
+		{
+			name:   "child listed first",
+			items:  []string{"parse", "compile"},
+			parent: map[string]string{"parse": "compile"},
+			want:   "compile(parse)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []*TimerItem
+			for _, name := range tt.items {
+				items = append(items, &TimerItem{Name: name})
+			}
+
+			if got := formatTimerItems(nestTimerItems(items, tt.parent)); got != tt.want {
+				t.Errorf("nestTimerItems = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// formatTimerItems writes the names of items and their children as
+// "a(b c) d", for comparing tree shapes.
+func formatTimerItems(items []*TimerItem) string {
+	var names []string
+	for _, item := range items {
+		name := item.Name
+		if len(item.Children) > 0 {
+			name += "(" + formatTimerItems(item.Children) + ")"
+		}
+
+		names = append(names, name)
+	}
+
+	return strings.Join(names, " ")
+}
+
+func formatTimerReport(r *TimerReport) string {
+	var b strings.Builder
+	for _, items := range [][]*TimerItem{r.Phases, r.Items, {r.Total}} {
+		for _, item := range items {
+			fmt.Fprintf(&b, "\n  %+v", item)
+		}
+	}
+
+	return b.String()
+}
@@ -264,9 +264,7 @@ func main() {
 	}
 
 	c.curblock.EndWithReturn(nil, c.intZero)
-	c.ctx.CompileToFile(gccjit.OUTPUT_KIND_EXECUTABLE, "a.out")
-
-	if strerr := c.ctx.GetFirstError(); strerr != "" {
-		println(strerr)
+	if err := c.ctx.CompileToFileE(gccjit.OUTPUT_KIND_EXECUTABLE, "a.out"); err != nil {
+		println(err.Error())
 	}
 }
@@ -38,15 +38,17 @@ func main() {
 
 	block.EndWithVoidReturn(nil)
 
-	res := ctx.Compile()
-	if res == nil {
-		panic(ctx.GetLastError())
+	res, err := ctx.CompileE()
+	if err != nil {
+		panic(err)
 	}
 
 	defer res.Release()
 
-	var greet func(name string)
-	res.RegisterFunc("greet", &greet)
+	greet, err := gccjit.Func[func(name string)](res, fn)
+	if err != nil {
+		panic(err)
+	}
 
 	greet("world")
 }
@@ -626,5 +626,7 @@ func main() {
 	}
 
 	// ctx.DumpToFile("./test.txt", false)
-	ctx.CompileToFile(gccjit.OUTPUT_KIND_EXECUTABLE, output)
+	if err := ctx.CompileToFileE(gccjit.OUTPUT_KIND_EXECUTABLE, output); err != nil {
+		panic(err)
+	}
 }